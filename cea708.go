@@ -35,153 +35,277 @@ References: https://en.wikipedia.org/wiki/CEA-708
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 )
 
-type cea708Provider int
+// Provider is the ITU-T T.35 terminal provider code of a payload
+type Provider int
 
 const (
-	Provider_DirectTV cea708Provider = 47
-	Provider_ATSC     cea708Provider = 49
+	Provider_DirectTV Provider = 47
+	Provider_ATSC     Provider = 49
 )
 
-type cea708_cc_type byte
+// CCType identifies the contents of a cc_data triplet.
+type CCType byte
 
 const (
-	ntsc_cc_field_1    cea708_cc_type = 0
-	ntsc_cc_field_2    cea708_cc_type = 1
-	dvtcc_packet_data  cea708_cc_type = 2
-	dvtcc_packet_start cea708_cc_type = 3
+	CCType_NTSCField1 CCType = 0
+	CCType_NTSCField2 CCType = 1
+	CCType_DTVCCData  CCType = 2
+	CCType_DTVCCStart CCType = 3
 )
 
-type cea708_cc_data struct {
-	marker_bits byte // 'one_bit' and 'reserved' in CEA-708
-	cc_valid    bool
-	cc_type     cea708_cc_type
-	cc_data     uint16
+// CCData is a single cc_data triplet.
+type CCData struct {
+	// 'one_bit' and 'reserved' in CEA-708, normally all ones
+	MarkerBits byte
+	Valid      bool
+	Type       CCType
+	// The 2 bytes of data, with 608 parity bits still in place
+	Data uint16
 }
 
-type cea708_user_data struct {
-	process_em_data_flag bool
-	process_cc_data_flag bool
-	additional_data_flag bool
-	cc_count             byte
-	em_data              byte
-	cc_data              []cea708_cc_data
+// Field returns the 608 field (1 or 2) the pair belongs to, or 0 for DTVCC data.
+func (cc CCData) Field() int {
+	switch cc.Type {
+	case CCType_NTSCField1:
+		return 1
+	case CCType_NTSCField2:
+		return 2
+	}
+	return 0
 }
 
-type cea708 struct {
-	provider            cea708Provider
-	user_identifier     uint32
-	user_data_type_code int
+// UserData is a H.264 SEI payload of "Registered User Data ITU-T T.35"
+// carrying CEA-708 cc_data.
+type UserData struct {
+	CountryCode int
+	// only set when CountryCode is 0xFF
+	CountryCodeExtension byte
+	Provider             Provider
+	// "GA94" for ATSC
+	UserIdentifier uint32
+	TypeCode       int
 
-	// fields specific to H.264 SEI type 4 - user data registered by ITU-T T.35
-	itu_t_t35_country_code                int
-	itu_t_t35_country_code_extension_byte byte
+	ProcessEMData  bool
+	ProcessCCData  bool
+	AdditionalData bool
+	CCCount        byte
+	EMData         byte
+	CCData         []CCData
 }
 
-// CEA708ToCCData takes a H.264 SEI payload of "Registered User Data ITU-T T.35"
-// and returns a list of 608 bytes that have passed validity checking
-func CEA708ToCCData(data []byte) ([]uint16, error) {
-	user_data, err := parseCEA708(data)
+var (
+	// ErrCEA708Truncated is returned when a payload ends before a field it
+	// needs
+	ErrCEA708Truncated = errors.New("truncated cea708 payload")
+	// ErrCEA708CCCount is returned when a payload has fewer triplets than its
+	// cc_count
+	ErrCEA708CCCount = errors.New("mismatched cc count")
+	// ErrCEA708UserDataType is returned for user data that is not cc_data
+	ErrCEA708UserDataType = errors.New("unsupported cea708 user data type")
+)
+
+// CEA708Stats counts what a CEA708Parser has seen
+type CEA708Stats struct {
+	// payloads passed to the parser
+	Payloads int
+	// cc_data triplets found
+	Triplets int
+	// triplets with cc_valid unset, and valid 608 pairs that are padding
+	Padding int
+	// payloads that could not be parsed, including mismatched cc_count
+	ParseErrors int
+	// payloads with fewer triplets than their cc_count
+	CCCountMismatches int
+}
+
+// CEA708Parser parses CEA-708 payloads, keeping stats. The zero value is
+// ready to use.
+type CEA708Parser struct {
+	// Trace, if set, is called with a description of every payload and
+	// cc_data triplet
+	Trace func(string)
+
+	stats CEA708Stats
+}
+
+// Stats returns what was counted since the parser was created
+func (p *CEA708Parser) Stats() CEA708Stats {
+	return p.stats
+}
+
+func (p *CEA708Parser) tracef(format string, a ...interface{}) {
+	if p.Trace != nil {
+		p.Trace(fmt.Sprintf(format, a...))
+	}
+}
+
+// Parse is the package level Parse, keeping stats
+func (p *CEA708Parser) Parse(data []byte) (*UserData, error) {
+	p.stats.Payloads++
+	user_data, err := Parse(data)
+	if err != nil {
+		p.stats.ParseErrors++
+		if errors.Is(err, ErrCEA708CCCount) {
+			p.stats.CCCountMismatches++
+		}
+		p.tracef("cea708 error: %v", err)
+		return nil, err
+	}
+	p.stats.Triplets += len(user_data.CCData)
+	for _, cd := range user_data.CCData {
+		if !cd.Valid || cd.Field() != 0 && cd.Data&0x7F7F == 0 {
+			p.stats.Padding++
+		}
+	}
+	if p.Trace != nil {
+		p.tracef("cea708 cc_count %d em_data 0x%02X", user_data.CCCount, user_data.EMData)
+		for _, cd := range user_data.CCData {
+			p.tracef("cea708 cc_valid %t cc_type %d 0x%04X", cd.Valid, cd.Type, cd.Data)
+		}
+	}
+	return user_data, nil
+}
+
+// CCData is CEA708ToCCData, keeping stats
+func (p *CEA708Parser) CCData(data []byte) ([]uint16, error) {
+	user_data, err := p.Parse(data)
 	if err != nil {
 		return nil, err
 	}
 
 	// Sometimes providers insert weird crud, so filter out the invalid ones
-	// TODO prob need to fix for multi-language
 	return printableCCData(user_data), nil
 }
 
-func isPrintable(cd *cea708_cc_data) bool {
-	return cd.cc_valid && cd.cc_type == ntsc_cc_field_1
+// CCPairs is CEA708ToCCPairs, keeping stats
+func (p *CEA708Parser) CCPairs(data []byte) ([]CCData, error) {
+	user_data, err := p.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	d := []CCData{}
+	for _, cd := range user_data.CCData {
+		if cd.Field() == 0 {
+			continue
+		}
+		d = append(d, cd)
+	}
+	return d, nil
 }
 
-func printableCCData(ud *cea708_user_data) []uint16 {
+// CEA708ToCCData takes a H.264 SEI payload of "Registered User Data ITU-T T.35"
+// and returns a list of 608 bytes that have passed validity checking
+func CEA708ToCCData(data []byte) ([]uint16, error) {
+	return (&CEA708Parser{}).CCData(data)
+}
+
+// CEA708ToCCPairs takes a H.264 SEI payload of "Registered User Data ITU-T T.35"
+// and returns the 608 pairs of both fields. Pairs are returned as-is, so the
+// caller must check Valid and can route each pair by its Field.
+func CEA708ToCCPairs(data []byte) ([]CCData, error) {
+	return (&CEA708Parser{}).CCPairs(data)
+}
+
+func isPrintable(cd *CCData) bool {
+	return cd.Valid && cd.Type == CCType_NTSCField1
+}
+
+func printableCCData(ud *UserData) []uint16 {
 	d := []uint16{}
-	for _, cd := range ud.cc_data {
+	for _, cd := range ud.CCData {
 		if !isPrintable(&cd) {
 			continue
 		}
-		d = append(d, cd.cc_data)
+		d = append(d, cd.Data)
 	}
 	return d
 }
 
-func parseCEA708UserData(data []byte) (*cea708_user_data, error) {
+func parseCEA708UserData(ud *UserData, data []byte) error {
 
 	if len(data) <= 2 {
-		return nil, errors.New("insufficient cea708 user data")
+		return fmt.Errorf("%w: user data", ErrCEA708Truncated)
 	}
 
-	ud := cea708_user_data{}
-	ud.process_em_data_flag = data[0]&0x80 == 0x80
-	ud.process_cc_data_flag = data[0]&0x40 == 0x40
-	ud.additional_data_flag = data[0]&0x20 == 0x20
-	ud.cc_count = data[0] & 0x1F
-	ud.em_data = data[1]
-	ud.cc_data = make([]cea708_cc_data, 0, 32)
+	ud.ProcessEMData = data[0]&0x80 == 0x80
+	ud.ProcessCCData = data[0]&0x40 == 0x40
+	ud.AdditionalData = data[0]&0x20 == 0x20
+	ud.CCCount = data[0] & 0x1F
+	ud.EMData = data[1]
+	ud.CCData = make([]CCData, 0, 32)
 
-	for i := 2; i+2 < len(data) && byte(len(ud.cc_data)) < ud.cc_count; i += 3 {
+	for i := 2; i+2 < len(data) && byte(len(ud.CCData)) < ud.CCCount; i += 3 {
 		d := data[i : i+3]
-		cc_data := cea708_cc_data{
-			marker_bits: d[0] >> 3,
-			cc_valid:    d[0]&0x40 == 0x40,
-			cc_type:     cea708_cc_type(d[0] & 0x3),
-			cc_data:     binary.BigEndian.Uint16(d[1:3]),
+		cc_data := CCData{
+			MarkerBits: d[0] >> 3,
+			Valid:      d[0]&0x04 == 0x04,
+			Type:       CCType(d[0] & 0x3),
+			Data:       binary.BigEndian.Uint16(d[1:3]),
 		}
-		ud.cc_data = append(ud.cc_data, cc_data)
+		ud.CCData = append(ud.CCData, cc_data)
 	}
 
 	// some checking
-	if len(ud.cc_data) != int(ud.cc_count) {
-		return nil, errors.New("mismatched cc count")
+	if len(ud.CCData) != int(ud.CCCount) {
+		return ErrCEA708CCCount
 	}
 
-	return &ud, nil
+	return nil
 }
 
-// Parses a CEA-708 packet.
-func parseCEA708(data []byte) (*cea708_user_data, error) {
+// Parse parses a H.264 SEI payload of "Registered User Data ITU-T T.35"
+// carrying CEA-708 cc_data. Errors are one of the ErrCEA708 errors, possibly
+// wrapped.
+func Parse(data []byte) (*UserData, error) {
 	sz := len(data)
 	if sz < 4 {
-		return nil, errors.New("insuffucient data to detect payload size")
+		return nil, fmt.Errorf("%w: payload size", ErrCEA708Truncated)
 	}
 	i := 0
-	c := cea708{}
+	c := UserData{}
 
-	c.itu_t_t35_country_code = int(data[i])
+	c.CountryCode = int(data[i])
 	i += 1
-	if c.itu_t_t35_country_code == 0xFF {
-		c.itu_t_t35_country_code_extension_byte = data[i]
+	if c.CountryCode == 0xFF {
+		c.CountryCodeExtension = data[i]
 		i += 1
 	}
-	c.provider = cea708Provider(binary.BigEndian.Uint16(data[i : i+2]))
+	c.Provider = Provider(binary.BigEndian.Uint16(data[i : i+2]))
 	i += 2
-	if c.provider == Provider_ATSC {
+	if c.Provider == Provider_ATSC {
 		if sz-i < 4 {
-			return nil, errors.New("insufficient data to read user identifier")
+			return nil, fmt.Errorf("%w: user identifier", ErrCEA708Truncated)
 		}
-		c.user_identifier = uint32(binary.BigEndian.Uint32(data[i : i+4]))
+		c.UserIdentifier = uint32(binary.BigEndian.Uint32(data[i : i+4]))
 		i += 4
 	}
-	if 0 == c.provider && 0 == c.itu_t_t35_country_code {
+	if 0 == c.Provider && 0 == c.CountryCode {
 		// where country and provider are zero
 		// only seems to come up in onCaptionInfo
 		// h264 spec seems to describe this
 		i += 1
 	}
-	if c.provider == Provider_ATSC || c.provider == Provider_DirectTV { // ATSC or DirecTV
+	if c.Provider == Provider_ATSC || c.Provider == Provider_DirectTV { // ATSC or DirecTV
 		if sz-i <= 1 {
-			return nil, errors.New("insufficient data to read provider type code")
+			return nil, fmt.Errorf("%w: provider type code", ErrCEA708Truncated)
 		}
-		c.user_data_type_code = int(data[i])
+		c.TypeCode = int(data[i])
 		i += 1
 	}
 
-	if 3 == c.user_data_type_code && sz-i >= 2 {
-		// parse user data type structure
-		return parseCEA708UserData(data[i:])
+	if 3 != c.TypeCode {
+		return nil, ErrCEA708UserDataType
 	}
-
-	return nil, errors.New("trailing user data")
+	if sz-i < 2 {
+		return nil, fmt.Errorf("%w: user data", ErrCEA708Truncated)
+	}
+	// parse user data type structure
+	if err := parseCEA708UserData(&c, data[i:]); err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
@@ -0,0 +1,97 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Character sets of the 16-bit CEA-708 characters that follow the P16 prefix.
+*/
+
+import (
+	"sync"
+)
+
+// CEA708Charset selects how a service decodes the characters following P16
+type CEA708Charset int
+
+const (
+	// CEA708Charset_None ignores P16 characters
+	CEA708Charset_None CEA708Charset = iota
+	// CEA708Charset_UCS2 decodes P16 characters as UCS-2
+	CEA708Charset_UCS2
+	// CEA708Charset_KSC5601 decodes P16 characters as KS C 5601 (KS X 1001),
+	// in the EUC-KR form used by the Korean DTVCC services
+	CEA708Charset_KSC5601
+)
+
+// Decode returns the character of a P16 code, or false if the code is not
+// part of the character set
+func (c CEA708Charset) Decode(code uint16) (rune, bool) {
+	switch c {
+	case CEA708Charset_UCS2:
+		if code >= 0xD800 && code < 0xE000 {
+			return 0, false // surrogates
+		}
+		return rune(code), true
+	case CEA708Charset_KSC5601:
+		row, col := int(code>>8)-0xA1, int(code&0xFF)-0xA1
+		if row < 0 || row >= 94 || col < 0 || col >= 94 || ksc5601Table[94*row+col] == 0 {
+			return 0, false
+		}
+		return rune(ksc5601Table[94*row+col]), true
+	}
+	return 0, false
+}
+
+// Encode returns the P16 code of a character, or false if the character set
+// does not have it
+func (c CEA708Charset) Encode(r rune) (uint16, bool) {
+	switch c {
+	case CEA708Charset_UCS2:
+		if r < 0 || r > 0xFFFF || (r >= 0xD800 && r < 0xE000) {
+			return 0, false
+		}
+		return uint16(r), true
+	case CEA708Charset_KSC5601:
+		ksc5601Once.Do(func() {
+			ksc5601Index = map[rune]uint16{}
+			for i, r := range ksc5601Table {
+				if _, ok := ksc5601Index[rune(r)]; r != 0 && !ok {
+					ksc5601Index[rune(r)] = uint16(0xA1+i/94)<<8 | uint16(0xA1+i%94)
+				}
+			}
+		})
+		code, ok := ksc5601Index[r]
+		return code, ok
+	}
+	return 0, false
+}
+
+// ksc5601Index is the reverse of ksc5601Table, keeping the first code of a
+// repeated character. It is built on first use.
+var (
+	ksc5601Once  sync.Once
+	ksc5601Index map[rune]uint16
+)
@@ -0,0 +1,96 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test_CEA708Charset(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		charset CEA708Charset
+		code    uint16
+		r       rune
+	}{
+		{CEA708Charset_KSC5601, 0xB0A1, '가'},
+		{CEA708Charset_KSC5601, 0xC7D1, '한'},
+		{CEA708Charset_KSC5601, 0xB1DB, '글'},
+		{CEA708Charset_KSC5601, 0xA1A1, '　'},
+		{CEA708Charset_KSC5601, 0xA3C1, 'Ａ'},
+		{CEA708Charset_KSC5601, 0xF9D3, '漢'},
+		{CEA708Charset_UCS2, 0xAC00, '가'},
+		{CEA708Charset_UCS2, 0x00E9, 'é'},
+	} {
+		r, ok := tc.charset.Decode(tc.code)
+		assert.True(ok)
+		assert.Equal(tc.r, r)
+		code, ok := tc.charset.Encode(tc.r)
+		assert.True(ok)
+		assert.Equal(tc.code, code)
+	}
+
+	for _, code := range []uint16{0x0041, 0xA0A1, 0xB0A0, 0xB0FF, 0xFEFE} {
+		_, ok := CEA708Charset_KSC5601.Decode(code)
+		assert.False(ok)
+	}
+	_, ok := CEA708Charset_UCS2.Decode(0xD800)
+	assert.False(ok)
+	_, ok = CEA708Charset_None.Decode(0xB0A1)
+	assert.False(ok)
+	_, ok = CEA708Charset_KSC5601.Encode('A')
+	assert.False(ok)
+	_, ok = CEA708Charset_UCS2.Encode('🅭')
+	assert.False(ok)
+}
+
+func Test_CEA708ServiceCharset(t *testing.T) {
+	assert := assert.New(t)
+
+	// DF0 visible, 1x10, "A", P16 한, P16 글, "B"
+	block := []byte{0x98, 0x20, 0x00, 0x00, 0x00, 0x09, 0x10, 'A', 0x18, 0xC7, 0xD1, 0x18, 0xB1, 0xDB, 'B'}
+
+	s := CEA708Service{}
+	assert.Nil(s.DecodeBlock(block))
+	assert.Equal("AB", s.String())
+
+	s = CEA708Service{Charset: CEA708Charset_KSC5601}
+	assert.Nil(s.DecodeBlock(block))
+	assert.Equal("A한글B", s.String())
+
+	// RST keeps the character set
+	s.Process(CEA708Reset{})
+	assert.Nil(s.DecodeBlock(block))
+	assert.Equal("A한글B", s.String())
+
+	d := CEA708Decoder{}
+	d.Service(1).Charset = CEA708Charset_UCS2
+	assert.Equal(CEA708Charset_UCS2, d.Service(1).Charset)
+	assert.Equal(CEA708Charset_None, d.Service(2).Charset)
+}
@@ -0,0 +1,430 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Parser for the commands of CEA-708 caption service blocks.
+
+References: https://shop.cta.tech/products/digital-television-dtv-closed-captioning
+*/
+
+import "errors"
+
+// ErrCEA708CommandTruncated is returned when a command runs past the end of
+// its service block
+var ErrCEA708CommandTruncated = errors.New("truncated cea708 command")
+
+const (
+	// C0 code set
+	cea708_c0_nul  = 0x00
+	cea708_c0_etx  = 0x03
+	cea708_c0_bs   = 0x08
+	cea708_c0_ff   = 0x0C
+	cea708_c0_cr   = 0x0D
+	cea708_c0_hcr  = 0x0E
+	cea708_c0_ext1 = 0x10
+	cea708_c0_p16  = 0x18
+
+	// C1 code set
+	cea708_c1_cw0 = 0x80 // to 0x87
+	cea708_c1_clw = 0x88
+	cea708_c1_dsw = 0x89
+	cea708_c1_hdw = 0x8A
+	cea708_c1_tgw = 0x8B
+	cea708_c1_dlw = 0x8C
+	cea708_c1_dly = 0x8D
+	cea708_c1_dlc = 0x8E
+	cea708_c1_rst = 0x8F
+	cea708_c1_spa = 0x90
+	cea708_c1_spc = 0x91
+	cea708_c1_spl = 0x92
+	cea708_c1_swa = 0x97
+	cea708_c1_df0 = 0x98 // to 0x9F
+)
+
+// CEA708Command is a command of a service block, one of the CEA708* command
+// types below.
+type CEA708Command interface{}
+
+// CEA708Text is a run of G0 and G1 characters
+type CEA708Text struct {
+	Text string
+}
+
+// CEA708Control is a C0 control code: ETX, BS, FF, CR or HCR
+type CEA708Control struct {
+	Code byte
+}
+
+// CEA708P16 is a 16-bit character code following the P16 prefix
+type CEA708P16 struct {
+	Code uint16
+}
+
+// CEA708Unsupported holds the bytes of a reserved or unused code, including
+// the EXT1 prefixed C2 and C3 codes and undefined G2 and G3 characters
+type CEA708Unsupported struct {
+	Data []byte
+}
+
+// CEA708SetCurrentWindow is CW0-CW7
+type CEA708SetCurrentWindow struct {
+	Window int
+}
+
+// CEA708ClearWindows is CLW. Windows is a bitmap, bit n for window n.
+type CEA708ClearWindows struct {
+	Windows byte
+}
+
+// CEA708DisplayWindows is DSW
+type CEA708DisplayWindows struct {
+	Windows byte
+}
+
+// CEA708HideWindows is HDW
+type CEA708HideWindows struct {
+	Windows byte
+}
+
+// CEA708ToggleWindows is TGW
+type CEA708ToggleWindows struct {
+	Windows byte
+}
+
+// CEA708DeleteWindows is DLW
+type CEA708DeleteWindows struct {
+	Windows byte
+}
+
+// CEA708Delay is DLY, in tenths of a second
+type CEA708Delay struct {
+	Tenths int
+}
+
+// CEA708DelayCancel is DLC
+type CEA708DelayCancel struct{}
+
+// CEA708Reset is RST
+type CEA708Reset struct{}
+
+// CEA708Color is a color with 2 bits, 0-3, for each of red, green and blue
+type CEA708Color struct {
+	R, G, B byte
+}
+
+func cea708Color(b byte) CEA708Color {
+	return CEA708Color{R: (b >> 4) & 0x03, G: (b >> 2) & 0x03, B: b & 0x03}
+}
+
+// CEA708Opacity is the opacity of a pen color or window fill
+type CEA708Opacity int
+
+const (
+	CEA708Opacity_Solid CEA708Opacity = iota
+	CEA708Opacity_Flash
+	CEA708Opacity_Translucent
+	CEA708Opacity_Transparent
+)
+
+// CEA708SetPenAttributes is SPA
+type CEA708SetPenAttributes struct {
+	// 0 small, 1 standard, 2 large
+	PenSize int
+	// 0 subscript, 1 normal, 2 superscript
+	Offset    int
+	TextTag   int
+	FontTag   int
+	EdgeType  int
+	Underline bool
+	Italics   bool
+}
+
+// CEA708SetPenColor is SPC
+type CEA708SetPenColor struct {
+	Foreground        CEA708Color
+	ForegroundOpacity CEA708Opacity
+	Background        CEA708Color
+	BackgroundOpacity CEA708Opacity
+	Edge              CEA708Color
+}
+
+// CEA708SetPenLocation is SPL
+type CEA708SetPenLocation struct {
+	Row, Col int
+}
+
+// CEA708SetWindowAttributes is SWA
+type CEA708SetWindowAttributes struct {
+	Fill            CEA708Color
+	FillOpacity     CEA708Opacity
+	BorderType      int
+	Border          CEA708Color
+	WordWrap        bool
+	PrintDirection  int
+	ScrollDirection int
+	Justify         int
+	EffectSpeed     int
+	EffectDirection int
+	DisplayEffect   int
+}
+
+// CEA708DefineWindow is DF0-DF7
+type CEA708DefineWindow struct {
+	Window   int
+	Priority int
+	// anchor point 0-8, from top left to bottom right
+	AnchorPoint         int
+	RelativePositioning bool
+	// in percent when RelativePositioning is set, otherwise in cells
+	AnchorVertical, AnchorHorizontal int
+	RowCount, ColumnCount            int
+	RowLock, ColumnLock              bool
+	Visible                          bool
+	WindowStyle, PenStyle            int
+}
+
+// cea708Length returns the length of the command starting with code. The
+// length of EXT1 codes depends on the code after it, see cea708ExtLength.
+func cea708Length(code byte) int {
+	switch {
+	case code < cea708_c0_ext1:
+		return 1
+	case code < cea708_c0_p16:
+		return 2
+	case code < 0x20:
+		return 3
+	case code < cea708_c1_cw0, code >= 0xA0:
+		return 1 // G0 and G1
+	case code <= cea708_c1_dly && code >= cea708_c1_clw:
+		return 2
+	case code == cea708_c1_spa, code == cea708_c1_spl:
+		return 3
+	case code == cea708_c1_spc:
+		return 4
+	case code == cea708_c1_swa:
+		return 5
+	case code >= cea708_c1_df0:
+		return 7
+	}
+	return 1 // CWx, DLC, RST and reserved
+}
+
+// cea708ExtLength returns the length of the code following EXT1, or 0 if
+// the data ends before the length is known
+func cea708ExtLength(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	code := data[0]
+	switch {
+	case code < 0x20:
+		return 1 + int(code>>3) // C2, 0-3 bytes
+	case code < 0x80, code >= 0xA0:
+		return 1 // G2 and G3
+	case code < 0x88:
+		return 5 // C3, 4 bytes
+	case code < 0x90:
+		return 6 // C3, 5 bytes
+	}
+	// C3 variable length, the next byte holds the length in its low 6 bits
+	if len(data) < 2 {
+		return 0
+	}
+	return 2 + int(data[1]&0x3F)
+}
+
+// g0g1Char returns the character of a G0 or G1 code
+func g0g1Char(code byte) rune {
+	if code == 0x7F {
+		return '♪'
+	}
+	return rune(code) // G0 is ASCII and G1 is ISO 8859-1
+}
+
+// g2g3Chars holds the characters of the G2 and G3 code sets, following EXT1
+var g2g3Chars = map[byte]rune{
+	0x20: ' ',      // transparent space
+	0x21: '\u00A0', // non-breaking transparent space
+	0x25: '…',
+	0x2A: 'Š',
+	0x2C: 'Œ',
+	0x30: '█',
+	0x31: '‘',
+	0x32: '’',
+	0x33: '“',
+	0x34: '”',
+	0x35: '•',
+	0x39: '™',
+	0x3A: 'š',
+	0x3C: 'œ',
+	0x3D: '℠',
+	0x3F: 'Ÿ',
+	0x76: '⅛',
+	0x77: '⅜',
+	0x78: '⅝',
+	0x79: '⅞',
+	0x7A: '│',
+	0x7B: '┐',
+	0x7C: '└',
+	0x7D: '─',
+	0x7E: '┘',
+	0x7F: '┌',
+	0xA0: '🅭', // G3 closed caption logo
+}
+
+// ParseServiceBlock returns the commands of the data of a service block. Runs
+// of text are returned as a single CEA708Text. The commands before an error
+// are returned.
+func ParseServiceBlock(data []byte) ([]CEA708Command, error) {
+	commands := []CEA708Command{}
+	text := []rune{}
+	flush := func() {
+		if len(text) > 0 {
+			commands = append(commands, CEA708Text{Text: string(text)})
+			text = text[:0]
+		}
+	}
+	for i := 0; i < len(data); {
+		code := data[i]
+		n := cea708Length(code)
+		if code == cea708_c0_ext1 {
+			n = 1 + cea708ExtLength(data[i+1:])
+		}
+		if (code == cea708_c0_ext1 && n == 1) || i+n > len(data) {
+			flush()
+			return commands, ErrCEA708CommandTruncated
+		}
+		p := data[i+1 : i+n]
+		i += n
+
+		if (code >= 0x20 && code < cea708_c1_cw0) || code >= 0xA0 {
+			text = append(text, g0g1Char(code))
+			continue
+		}
+		if code == cea708_c0_ext1 {
+			if r, ok := g2g3Chars[p[0]]; ok {
+				text = append(text, r)
+				continue
+			}
+		}
+		flush()
+		if c := parseCEA708Command(code, p); c != nil {
+			commands = append(commands, c)
+		}
+	}
+	flush()
+	return commands, nil
+}
+
+// Commands returns the commands of the service block
+func (b *DTVCCServiceBlock) Commands() ([]CEA708Command, error) {
+	return ParseServiceBlock(b.Data)
+}
+
+// parseCEA708Command parses a C0 or C1 command with its parameters p
+func parseCEA708Command(code byte, p []byte) CEA708Command {
+	switch {
+	case code >= cea708_c1_cw0 && code < cea708_c1_clw:
+		return CEA708SetCurrentWindow{Window: int(code - cea708_c1_cw0)}
+	case code >= cea708_c1_df0:
+		return CEA708DefineWindow{
+			Window:              int(code - cea708_c1_df0),
+			Visible:             p[0]&0x20 != 0,
+			RowLock:             p[0]&0x10 != 0,
+			ColumnLock:          p[0]&0x08 != 0,
+			Priority:            int(p[0] & 0x07),
+			RelativePositioning: p[1]&0x80 != 0,
+			AnchorVertical:      int(p[1] & 0x7F),
+			AnchorHorizontal:    int(p[2]),
+			AnchorPoint:         int(p[3] >> 4),
+			RowCount:            int(p[3]&0x0F) + 1,
+			ColumnCount:         int(p[4]&0x3F) + 1,
+			WindowStyle:         int(p[5]>>3) & 0x07,
+			PenStyle:            int(p[5] & 0x07),
+		}
+	}
+
+	switch code {
+	case cea708_c0_nul:
+		return nil
+	case cea708_c0_etx, cea708_c0_bs, cea708_c0_ff, cea708_c0_cr, cea708_c0_hcr:
+		return CEA708Control{Code: code}
+	case cea708_c0_p16:
+		return CEA708P16{Code: uint16(p[0])<<8 | uint16(p[1])}
+	case cea708_c1_clw:
+		return CEA708ClearWindows{Windows: p[0]}
+	case cea708_c1_dsw:
+		return CEA708DisplayWindows{Windows: p[0]}
+	case cea708_c1_hdw:
+		return CEA708HideWindows{Windows: p[0]}
+	case cea708_c1_tgw:
+		return CEA708ToggleWindows{Windows: p[0]}
+	case cea708_c1_dlw:
+		return CEA708DeleteWindows{Windows: p[0]}
+	case cea708_c1_dly:
+		return CEA708Delay{Tenths: int(p[0])}
+	case cea708_c1_dlc:
+		return CEA708DelayCancel{}
+	case cea708_c1_rst:
+		return CEA708Reset{}
+	case cea708_c1_spa:
+		return CEA708SetPenAttributes{
+			TextTag:   int(p[0] >> 4),
+			Offset:    int(p[0]>>2) & 0x03,
+			PenSize:   int(p[0] & 0x03),
+			Italics:   p[1]&0x80 != 0,
+			Underline: p[1]&0x40 != 0,
+			EdgeType:  int(p[1]>>3) & 0x07,
+			FontTag:   int(p[1] & 0x07),
+		}
+	case cea708_c1_spc:
+		return CEA708SetPenColor{
+			ForegroundOpacity: CEA708Opacity(p[0] >> 6),
+			Foreground:        cea708Color(p[0]),
+			BackgroundOpacity: CEA708Opacity(p[1] >> 6),
+			Background:        cea708Color(p[1]),
+			Edge:              cea708Color(p[2]),
+		}
+	case cea708_c1_spl:
+		return CEA708SetPenLocation{Row: int(p[0] & 0x0F), Col: int(p[1] & 0x3F)}
+	case cea708_c1_swa:
+		return CEA708SetWindowAttributes{
+			FillOpacity:     CEA708Opacity(p[0] >> 6),
+			Fill:            cea708Color(p[0]),
+			BorderType:      int(p[1]>>6) | int(p[2]>>7)<<2,
+			Border:          cea708Color(p[1]),
+			WordWrap:        p[2]&0x40 != 0,
+			PrintDirection:  int(p[2]>>4) & 0x03,
+			ScrollDirection: int(p[2]>>2) & 0x03,
+			Justify:         int(p[2] & 0x03),
+			EffectSpeed:     int(p[3] >> 4),
+			EffectDirection: int(p[3]>>2) & 0x03,
+			DisplayEffect:   int(p[3] & 0x03),
+		}
+	}
+	return CEA708Unsupported{Data: append([]byte{code}, p...)}
+}
@@ -0,0 +1,113 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test_ParseServiceBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	commands, err := ParseServiceBlock([]byte{
+		0x98, 0x38, 0x84, 0x10, 0x72, 0x1F, 0x09, // DF0 visible, row and column lock, relative 4% 16%, anchor 7, 3x32, styles 1 1
+		0x97, 0x2A, 0x00, 0x52, 0x00, // SWA fill gray, word wrap, left to right, bottom to top, center
+		0x90, 0x05, 0xC0, // SPA standard, normal, italics underline
+		0x91, 0xFF, 0x80, 0x00, // SPC transparent white on translucent black
+		0x92, 0x02, 0x05, // SPL row 2 col 5
+		0x80,                 // CW0
+		'H', 'i', 0x7F, 0xE9, // "Hi♪é"
+		0x00, 0x0D, 0x41, // NUL, CR, "A"
+		0x89, 0x01, 0x8D, 0x0A, 0x8E, 0x8F, // DSW 0, DLY 1s, DLC, RST
+		0x18, 0xB0, 0xA1, 0x03, 0x93, // P16, ETX, reserved
+	})
+	assert.Nil(err)
+	assert.Equal([]CEA708Command{
+		CEA708DefineWindow{
+			Window: 0, Visible: true, RowLock: true, ColumnLock: true, RelativePositioning: true,
+			AnchorVertical: 4, AnchorHorizontal: 16, AnchorPoint: 7, RowCount: 3, ColumnCount: 32,
+			WindowStyle: 1, PenStyle: 1,
+		},
+		CEA708SetWindowAttributes{Fill: CEA708Color{2, 2, 2}, WordWrap: true, PrintDirection: 1, ScrollDirection: 0, Justify: 2, EffectDirection: 0},
+		CEA708SetPenAttributes{PenSize: 1, Offset: 1, Italics: true, Underline: true},
+		CEA708SetPenColor{
+			Foreground: CEA708Color{3, 3, 3}, ForegroundOpacity: CEA708Opacity_Transparent,
+			BackgroundOpacity: CEA708Opacity_Translucent,
+		},
+		CEA708SetPenLocation{Row: 2, Col: 5},
+		CEA708SetCurrentWindow{Window: 0},
+		CEA708Text{Text: "Hi♪é"},
+		CEA708Control{Code: 0x0D},
+		CEA708Text{Text: "A"},
+		CEA708DisplayWindows{Windows: 0x01},
+		CEA708Delay{Tenths: 10},
+		CEA708DelayCancel{},
+		CEA708Reset{},
+		CEA708P16{Code: 0xB0A1},
+		CEA708Control{Code: 0x03},
+		CEA708Unsupported{Data: []byte{0x93}},
+	}, commands)
+
+	block := DTVCCServiceBlock{Service: 1, Data: []byte{'O', 'K', 0x91, 0x00}}
+	commands, err = block.Commands()
+	assert.Equal(ErrCEA708CommandTruncated, err)
+	assert.Equal([]CEA708Command{CEA708Text{Text: "OK"}}, commands)
+}
+
+func Test_ParseServiceBlockExtended(t *testing.T) {
+	assert := assert.New(t)
+
+	commands, err := ParseServiceBlock([]byte{
+		'A', 0x10, 0x25, 0x10, 0x39, 0x10, 0x7F, 0x10, 0xA0, // "A…™┌", CC logo
+		0x10, 0x03, // C2, no parameters
+		0x10, 0x0B, 0x01, // C2, 1 parameter
+		0x10, 0x1A, 0x01, 0x02, 0x03, // C2, 3 parameters
+		0x10, 0x84, 0x01, 0x02, 0x03, 0x04, // C3, 4 parameters
+		0x10, 0x8C, 0x01, 0x02, 0x03, 0x04, 0x05, // C3, 5 parameters
+		0x10, 0x90, 0x43, 0x8D, 0x8D, 0x8D, // C3 variable length, 3 bytes
+		0x10, 0x22, // undefined G2
+		'B',
+	})
+	assert.Nil(err)
+	assert.Equal([]CEA708Command{
+		CEA708Text{Text: "A…™┌🅭"},
+		CEA708Unsupported{Data: []byte{0x10, 0x03}},
+		CEA708Unsupported{Data: []byte{0x10, 0x0B, 0x01}},
+		CEA708Unsupported{Data: []byte{0x10, 0x1A, 0x01, 0x02, 0x03}},
+		CEA708Unsupported{Data: []byte{0x10, 0x84, 0x01, 0x02, 0x03, 0x04}},
+		CEA708Unsupported{Data: []byte{0x10, 0x8C, 0x01, 0x02, 0x03, 0x04, 0x05}},
+		CEA708Unsupported{Data: []byte{0x10, 0x90, 0x43, 0x8D, 0x8D, 0x8D}},
+		CEA708Unsupported{Data: []byte{0x10, 0x22}},
+		CEA708Text{Text: "B"},
+	}, commands)
+
+	for _, data := range [][]byte{{0x10}, {0x10, 0x90}, {0x10, 0x90, 0x02, 0x00}, {0x10, 0x1A, 0x01}} {
+		_, err := ParseServiceBlock(data)
+		assert.Equal(ErrCEA708CommandTruncated, err)
+	}
+}
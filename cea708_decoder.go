@@ -0,0 +1,713 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Decoder for CEA-708 caption services: the windows and pens the service block
+commands draw with.
+
+References: https://shop.cta.tech/products/digital-television-dtv-closed-captioning
+*/
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// print and scroll directions
+	cea708_direction_left_to_right = 0
+	cea708_direction_right_to_left = 1
+	cea708_direction_top_to_bottom = 2
+	cea708_direction_bottom_to_top = 3
+
+	// justification of the lines of a window
+	cea708_justify_left   = 0
+	cea708_justify_right  = 1
+	cea708_justify_center = 2
+	cea708_justify_full   = 3
+)
+
+// predefined window styles 1-7, from CEA-708
+var cea708WindowStyles = [...]CEA708SetWindowAttributes{
+	1: {ScrollDirection: cea708_direction_bottom_to_top},
+	2: {ScrollDirection: cea708_direction_bottom_to_top, FillOpacity: CEA708Opacity_Transparent},
+	3: {ScrollDirection: cea708_direction_bottom_to_top, Justify: cea708_justify_center},
+	4: {ScrollDirection: cea708_direction_bottom_to_top, WordWrap: true},
+	5: {ScrollDirection: cea708_direction_bottom_to_top, WordWrap: true, FillOpacity: CEA708Opacity_Transparent},
+	6: {ScrollDirection: cea708_direction_bottom_to_top, WordWrap: true, Justify: cea708_justify_center},
+	7: {PrintDirection: cea708_direction_top_to_bottom, ScrollDirection: cea708_direction_right_to_left},
+}
+
+// predefined pen styles 1-7, from CEA-708
+var cea708PenStyles = func() [8]CEA708Pen {
+	white := CEA708Color{R: 2, G: 2, B: 2}
+	styles := [8]CEA708Pen{}
+	for i := 1; i < len(styles); i++ {
+		styles[i].Attributes.PenSize = 1
+		styles[i].Attributes.Offset = 1
+		styles[i].Color.Foreground = white
+	}
+	styles[2].Attributes.FontTag = 1
+	styles[3].Attributes.FontTag = 2
+	styles[4].Attributes.FontTag = 3
+	styles[5].Attributes.FontTag = 4
+	styles[6].Attributes.FontTag = 3
+	styles[7].Attributes.FontTag = 4
+	for _, i := range []int{6, 7} {
+		styles[i].Attributes.EdgeType = 3 // uniform
+		styles[i].Color.BackgroundOpacity = CEA708Opacity_Transparent
+	}
+	return styles
+}()
+
+// CEA708Pen holds the attributes and colors text is written with
+type CEA708Pen struct {
+	Attributes CEA708SetPenAttributes
+	Color      CEA708SetPenColor
+}
+
+// CEA708Cell is a character cell of a window, Char is 0 for an empty cell
+type CEA708Cell struct {
+	Char rune
+	Pen  CEA708Pen
+}
+
+// CEA708Span is a run of adjacent characters on a row of a window sharing the
+// same pen
+type CEA708Span struct {
+	Row, Col int
+	Text     string
+	Pen      CEA708Pen
+}
+
+// CEA708Window is one of the eight windows of a caption service
+type CEA708Window struct {
+	ID      int
+	Defined bool
+	Visible bool
+	// 0 is the highest priority
+	Priority            int
+	AnchorPoint         int
+	RelativePositioning bool
+	// in percent when RelativePositioning is set, otherwise in cells
+	AnchorVertical, AnchorHorizontal int
+	RowCount, ColumnCount            int
+	RowLock, ColumnLock              bool
+	Attributes                       CEA708SetWindowAttributes
+	Pen                              CEA708Pen
+	// pen location
+	Row, Col int
+
+	cells [][]CEA708Cell
+	// the last character filled the line, with WordWrap the next one starts
+	// a new line
+	wrap bool
+}
+
+// Cell returns the cell at row and col as written, before justification, or
+// an empty cell if it is outside the window
+func (w *CEA708Window) Cell(row, col int) CEA708Cell {
+	if row < 0 || row >= len(w.cells) || col < 0 || col >= len(w.cells[row]) {
+		return CEA708Cell{}
+	}
+	return w.cells[row][col]
+}
+
+// Spans returns the justified text of the window, row by row
+func (w *CEA708Window) Spans() []CEA708Span {
+	spans := []CEA708Span{}
+	for r, row := range w.justified() {
+		for c := 0; c < len(row); c++ {
+			if row[c].Char == 0 {
+				continue
+			}
+			n := len(spans) - 1
+			if n >= 0 && spans[n].Row == r && spans[n].Col+len([]rune(spans[n].Text)) == c && spans[n].Pen == row[c].Pen {
+				spans[n].Text += string(row[c].Char)
+			} else {
+				spans = append(spans, CEA708Span{Row: r, Col: c, Text: string(row[c].Char), Pen: row[c].Pen})
+			}
+		}
+	}
+	return spans
+}
+
+// String returns the justified text of the window, with a line for each row
+// that is not empty. Empty cells before and between characters are spaces.
+func (w *CEA708Window) String() string {
+	var s []string
+	for _, row := range w.justified() {
+		var sb strings.Builder
+		for _, c := range row {
+			if c.Char == 0 {
+				sb.WriteRune(' ')
+				continue
+			}
+			sb.WriteRune(c.Char)
+		}
+		if line := strings.TrimRightFunc(sb.String(), unicode.IsSpace); strings.TrimSpace(line) != "" {
+			s = append(s, line)
+		}
+	}
+	return strings.Join(s, "\n")
+}
+
+// justified returns the cells of the window with every line justified. Left
+// justified lines keep the columns they were written at.
+func (w *CEA708Window) justified() [][]CEA708Cell {
+	if w.Attributes.Justify == cea708_justify_left {
+		return w.cells
+	}
+	cells := make([][]CEA708Cell, w.RowCount)
+	for r := range cells {
+		cells[r] = make([]CEA708Cell, w.ColumnCount)
+	}
+	j := *w
+	j.cells = cells
+	n := w.lineLength()
+	for l := 0; l < w.lines(); l++ {
+		// the characters from the first to the last cell that is not empty
+		line := []CEA708Cell{}
+		for p := 0; p < n; p++ {
+			if c := *w.cell(l, p); c.Char != 0 || len(line) > 0 {
+				line = append(line, c)
+			}
+		}
+		for len(line) > 0 && line[len(line)-1].Char == 0 {
+			line = line[:len(line)-1]
+		}
+
+		start := 0
+		switch w.Attributes.Justify {
+		case cea708_justify_right:
+			start = n - len(line)
+		case cea708_justify_center:
+			start = (n - len(line)) / 2
+		case cea708_justify_full:
+			if words := cea708Words(line); len(words) > 1 {
+				line = cea708Spread(words, n)
+			}
+		}
+		for p, c := range line {
+			*j.cell(l, start+p) = c
+		}
+	}
+	return cells
+}
+
+// cea708Words splits a line at empty cells and spaces
+func cea708Words(line []CEA708Cell) [][]CEA708Cell {
+	words := [][]CEA708Cell{}
+	for i := 0; i < len(line); {
+		if line[i].Char == 0 || line[i].Char == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(line) && line[j].Char != 0 && line[j].Char != ' ' {
+			j++
+		}
+		words = append(words, line[i:j])
+		i = j
+	}
+	return words
+}
+
+// cea708Spread returns a line of n cells with the words spread out to both
+// ends. The first gaps take any cells left over.
+func cea708Spread(words [][]CEA708Cell, n int) []CEA708Cell {
+	space := n
+	for _, word := range words {
+		space -= len(word)
+	}
+	gaps := len(words) - 1
+	line := []CEA708Cell{}
+	for i, word := range words {
+		line = append(line, word...)
+		if i < gaps {
+			gap := space / gaps
+			if i < space%gaps {
+				gap++
+			}
+			line = append(line, make([]CEA708Cell, gap)...)
+		}
+	}
+	return line
+}
+
+func (w *CEA708Window) define(c CEA708DefineWindow) {
+	created := !w.Defined
+	w.Defined = true
+	w.Visible = c.Visible
+	w.Priority = c.Priority
+	w.AnchorPoint = c.AnchorPoint
+	w.RelativePositioning = c.RelativePositioning
+	w.AnchorVertical, w.AnchorHorizontal = c.AnchorVertical, c.AnchorHorizontal
+	w.RowLock, w.ColumnLock = c.RowLock, c.ColumnLock
+
+	// style 0 is style 1 for a new window, and no change otherwise
+	windowStyle, penStyle := c.WindowStyle, c.PenStyle
+	if created {
+		w.Row, w.Col = 0, 0
+		if windowStyle == 0 {
+			windowStyle = 1
+		}
+		if penStyle == 0 {
+			penStyle = 1
+		}
+	}
+	if windowStyle != 0 {
+		w.Attributes = cea708WindowStyles[windowStyle]
+	}
+	if penStyle != 0 {
+		w.Pen = cea708PenStyles[penStyle]
+	}
+
+	w.RowCount, w.ColumnCount = c.RowCount, c.ColumnCount
+	cells := make([][]CEA708Cell, w.RowCount)
+	for r := range cells {
+		cells[r] = make([]CEA708Cell, w.ColumnCount)
+		if r < len(w.cells) {
+			copy(cells[r], w.cells[r])
+		}
+	}
+	w.cells = cells
+	w.clampPen()
+}
+
+func (w *CEA708Window) clampPen() {
+	w.wrap = false
+	if w.Row >= w.RowCount {
+		w.Row = w.RowCount - 1
+	}
+	if w.Col >= w.ColumnCount {
+		w.Col = w.ColumnCount - 1
+	}
+	if w.Row < 0 {
+		w.Row = 0
+	}
+	if w.Col < 0 {
+		w.Col = 0
+	}
+}
+
+func (w *CEA708Window) clear() {
+	w.wrap = false
+	for r := range w.cells {
+		w.cells[r] = make([]CEA708Cell, w.ColumnCount)
+	}
+}
+
+// horizontal returns true if text is printed along rows
+func (w *CEA708Window) horizontal() bool {
+	d := w.Attributes.PrintDirection
+	return d == cea708_direction_left_to_right || d == cea708_direction_right_to_left
+}
+
+// lines returns the number of lines of the window: rows when printing along
+// rows, otherwise columns
+func (w *CEA708Window) lines() int {
+	if w.horizontal() {
+		return w.RowCount
+	}
+	return w.ColumnCount
+}
+
+// lineLength returns the number of cells of a line
+func (w *CEA708Window) lineLength() int {
+	if w.horizontal() {
+		return w.ColumnCount
+	}
+	return w.RowCount
+}
+
+// cell returns the cell at position pos, left to right or top to bottom, of
+// line l
+func (w *CEA708Window) cell(l, pos int) *CEA708Cell {
+	if w.horizontal() {
+		return &w.cells[l][pos]
+	}
+	return &w.cells[pos][l]
+}
+
+// pen returns the line and position of the pen
+func (w *CEA708Window) pen() (int, int) {
+	if w.horizontal() {
+		return w.Row, w.Col
+	}
+	return w.Col, w.Row
+}
+
+// setPen moves the pen to line l, position pos
+func (w *CEA708Window) setPen(l, pos int) {
+	if w.horizontal() {
+		w.Row, w.Col = l, pos
+	} else {
+		w.Row, w.Col = pos, l
+	}
+}
+
+// step returns the change of position from one character to the next
+func (w *CEA708Window) step() int {
+	d := w.Attributes.PrintDirection
+	if d == cea708_direction_right_to_left || d == cea708_direction_bottom_to_top {
+		return -1
+	}
+	return 1
+}
+
+// lineStep returns the change of line from one line to the next, which is
+// against the scroll direction. Scroll directions along the lines are
+// invalid, they scroll up or left like the predefined window styles.
+func (w *CEA708Window) lineStep() int {
+	d := w.Attributes.ScrollDirection
+	if w.horizontal() && d == cea708_direction_top_to_bottom || !w.horizontal() && d == cea708_direction_left_to_right {
+		return -1
+	}
+	return 1
+}
+
+func (w *CEA708Window) put(r rune) {
+	if w.wrap {
+		if r == ' ' {
+			w.carriageReturn() // the space breaks the line
+			return
+		}
+		word := w.lastWord()
+		w.carriageReturn()
+		for _, c := range word {
+			w.write(c)
+		}
+	}
+	w.write(CEA708Cell{Char: r, Pen: w.Pen})
+}
+
+// write writes a cell at the pen and moves the pen to the next position. At
+// the end of the line the pen stays on the last cell, with WordWrap the next
+// character starts a new line.
+func (w *CEA708Window) write(c CEA708Cell) {
+	l, pos := w.pen()
+	*w.cell(l, pos) = c
+	if pos += w.step(); pos >= 0 && pos < w.lineLength() {
+		w.setPen(l, pos)
+		return
+	}
+	w.wrap = w.Attributes.WordWrap
+}
+
+// lastWord removes and returns the word ending at the pen, in print order,
+// unless it fills the line and has to be broken
+func (w *CEA708Window) lastWord() []CEA708Cell {
+	l, pos := w.pen()
+	n := 0
+	for p := pos; p >= 0 && p < w.lineLength(); p -= w.step() {
+		if c := w.cell(l, p).Char; c == 0 || c == ' ' {
+			break
+		}
+		n++
+	}
+	if n == w.lineLength() {
+		return nil
+	}
+	word := make([]CEA708Cell, n)
+	for i := range word {
+		p := pos - (n-1-i)*w.step()
+		word[i] = *w.cell(l, p)
+		*w.cell(l, p) = CEA708Cell{}
+	}
+	return word
+}
+
+func (w *CEA708Window) backspace() {
+	if w.wrap {
+		// the pen is still on the last character of the line
+		w.wrap = false
+		l, pos := w.pen()
+		*w.cell(l, pos) = CEA708Cell{}
+		return
+	}
+	l, pos := w.pen()
+	if pos -= w.step(); pos < 0 || pos >= w.lineLength() {
+		return
+	}
+	w.setPen(l, pos)
+	*w.cell(l, pos) = CEA708Cell{}
+}
+
+// lineStart moves the pen to the start of its line
+func (w *CEA708Window) lineStart() {
+	w.wrap = false
+	l, _ := w.pen()
+	if w.step() > 0 {
+		w.setPen(l, 0)
+	} else {
+		w.setPen(l, w.lineLength()-1)
+	}
+}
+
+// carriageReturn moves the pen to the start of the next line, scrolling the
+// window in the scroll direction when it is on the last line
+func (w *CEA708Window) carriageReturn() {
+	w.lineStart()
+	l, pos := w.pen()
+	if next := l + w.lineStep(); next >= 0 && next < w.lines() {
+		w.setPen(next, pos)
+		return
+	}
+	// move every line back by one, the line of the pen is the new one
+	for i := 0; i < w.lines()-1; i++ {
+		from, to := i+1, i
+		if w.lineStep() < 0 {
+			from, to = w.lines()-2-i, w.lines()-1-i
+		}
+		for p := 0; p < w.lineLength(); p++ {
+			*w.cell(to, p) = *w.cell(from, p)
+		}
+	}
+	for p := 0; p < w.lineLength(); p++ {
+		*w.cell(l, p) = CEA708Cell{}
+	}
+}
+
+// clearLine clears the line of the pen and moves it to the start of the line
+func (w *CEA708Window) clearLine() {
+	w.lineStart()
+	l, _ := w.pen()
+	for p := 0; p < w.lineLength(); p++ {
+		*w.cell(l, p) = CEA708Cell{}
+	}
+}
+
+// CEA708Service holds the windows of a caption service
+type CEA708Service struct {
+	// Charset decodes the characters following P16
+	Charset CEA708Charset
+
+	windows [8]CEA708Window
+	current int
+
+	// DLY state of the timed commands, see ProcessAt
+	delayed bool
+	until   time.Duration
+	queue   []CEA708Command
+}
+
+// window returns the current window, or nil if it is not defined
+func (s *CEA708Service) window() *CEA708Window {
+	if !s.windows[s.current].Defined {
+		return nil
+	}
+	return &s.windows[s.current]
+}
+
+// eachWindow calls f for every defined window in the bitmap
+func (s *CEA708Service) eachWindow(windows byte, f func(w *CEA708Window)) {
+	for i := range s.windows {
+		if windows&(1<<uint(i)) != 0 && s.windows[i].Defined {
+			f(&s.windows[i])
+		}
+	}
+}
+
+// validWindow returns true if id is one of the eight windows
+func validWindow(id int) bool {
+	return id >= 0 && id < 8
+}
+
+// validDefineWindow returns true if every value of a DFx command is in range
+func validDefineWindow(c CEA708DefineWindow) bool {
+	return validWindow(c.Window) && c.RowCount > 0 && c.ColumnCount > 0 &&
+		c.WindowStyle >= 0 && c.WindowStyle < len(cea708WindowStyles) &&
+		c.PenStyle >= 0 && c.PenStyle < len(cea708PenStyles)
+}
+
+// Process runs a command on the service. DLY and DLC have no effect, use
+// ProcessAt to honor them. Commands with an invalid window are ignored.
+func (s *CEA708Service) Process(c CEA708Command) {
+	switch c := c.(type) {
+	case CEA708DefineWindow:
+		if !validDefineWindow(c) {
+			return
+		}
+		s.windows[c.Window].ID = c.Window
+		s.windows[c.Window].define(c)
+		s.current = c.Window
+	case CEA708SetCurrentWindow:
+		if !validWindow(c.Window) {
+			return
+		}
+		s.current = c.Window
+	case CEA708ClearWindows:
+		s.eachWindow(c.Windows, func(w *CEA708Window) { w.clear() })
+	case CEA708DisplayWindows:
+		s.eachWindow(c.Windows, func(w *CEA708Window) { w.Visible = true })
+	case CEA708HideWindows:
+		s.eachWindow(c.Windows, func(w *CEA708Window) { w.Visible = false })
+	case CEA708ToggleWindows:
+		s.eachWindow(c.Windows, func(w *CEA708Window) { w.Visible = !w.Visible })
+	case CEA708DeleteWindows:
+		s.eachWindow(c.Windows, func(w *CEA708Window) { *w = CEA708Window{} })
+	case CEA708Reset:
+		*s = CEA708Service{Charset: s.Charset}
+	}
+
+	w := s.window()
+	if w == nil {
+		return
+	}
+	switch c := c.(type) {
+	case CEA708SetWindowAttributes:
+		w.Attributes = c
+	case CEA708SetPenAttributes:
+		w.Pen.Attributes = c
+	case CEA708SetPenColor:
+		w.Pen.Color = c
+	case CEA708SetPenLocation:
+		w.Row, w.Col = c.Row, c.Col
+		w.clampPen()
+	case CEA708Text:
+		for _, r := range c.Text {
+			w.put(r)
+		}
+	case CEA708P16:
+		if r, ok := s.Charset.Decode(c.Code); ok {
+			w.put(r)
+		}
+	case CEA708Control:
+		switch c.Code {
+		case cea708_c0_bs:
+			w.backspace()
+		case cea708_c0_ff:
+			w.clear()
+			w.Row, w.Col = 0, 0
+		case cea708_c0_cr:
+			w.carriageReturn()
+		case cea708_c0_hcr:
+			w.clearLine()
+		}
+	}
+}
+
+// DecodeBlock runs the commands of the data of a service block
+func (s *CEA708Service) DecodeBlock(data []byte) error {
+	commands, err := ParseServiceBlock(data)
+	for _, c := range commands {
+		s.Process(c)
+	}
+	return err
+}
+
+// Window returns one of the eight windows, 0-7, or an undefined window if id
+// is out of range
+func (s *CEA708Service) Window(id int) CEA708Window {
+	if !validWindow(id) {
+		return CEA708Window{}
+	}
+	return s.windows[id]
+}
+
+// VisibleWindows returns the defined windows that are visible, highest
+// priority first
+func (s *CEA708Service) VisibleWindows() []CEA708Window {
+	windows := []CEA708Window{}
+	for _, w := range s.windows {
+		if w.Defined && w.Visible {
+			windows = append(windows, w)
+		}
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Priority < windows[j].Priority })
+	return windows
+}
+
+// String returns the text of the visible windows, highest priority first
+func (s *CEA708Service) String() string {
+	var text []string
+	for _, w := range s.VisibleWindows() {
+		if t := w.String(); t != "" {
+			text = append(text, t)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// CEA708Decoder decodes the DTVCC data of a stream into caption services. The
+// zero value is ready to use.
+type CEA708Decoder struct {
+	assembler DTVCCAssembler
+	services  [64]CEA708Service
+}
+
+// Decode adds a cc_data triplet, returning the services that received
+// commands
+func (d *CEA708Decoder) Decode(cc CCData) ([]int, error) {
+	return d.decode(cc, []int{}, (*CEA708Service).DecodeBlock)
+}
+
+// decode adds a cc_data triplet, decoding the service blocks of the
+// completed packets with f
+func (d *CEA708Decoder) decode(cc CCData, updated []int, f func(s *CEA708Service, data []byte) error) ([]int, error) {
+	var err error
+	for _, p := range d.assembler.Add(cc) {
+		blocks, e := p.ServiceBlocks()
+		if e != nil && err == nil {
+			err = e
+		}
+		for _, b := range blocks {
+			if e := f(&d.services[b.Service], b.Data); e != nil && err == nil {
+				err = e
+			}
+			updated = append(updated, b.Service)
+		}
+	}
+	return updated, err
+}
+
+// DecodeUserData adds all triplets of a CEA-708 payload
+func (d *CEA708Decoder) DecodeUserData(ud *UserData) ([]int, error) {
+	updated := []int{}
+	var err error
+	for _, cc := range ud.CCData {
+		services, e := d.Decode(cc)
+		if e != nil && err == nil {
+			err = e
+		}
+		updated = append(updated, services...)
+	}
+	return updated, err
+}
+
+// Service returns a caption service, 1-63, or nil if n is out of range.
+// Blocks of the null service 0 are never decoded. Set the Charset of a
+// service to decode its P16 characters.
+func (d *CEA708Decoder) Service(n int) *CEA708Service {
+	if n < 1 || n >= len(d.services) {
+		return nil
+	}
+	return &d.services[n]
+}
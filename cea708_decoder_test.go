@@ -0,0 +1,205 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test_CEA708Service(t *testing.T) {
+	assert := assert.New(t)
+
+	s := CEA708Service{}
+	// DF1 visible, 2x10, window style 4 (roll-up), pen style 0 (default)
+	assert.Nil(s.DecodeBlock([]byte{0x99, 0x20, 0x00, 0x00, 0x01, 0x09, 0x20}))
+	w := s.Window(1)
+	assert.True(w.Defined)
+	assert.Equal(2, w.RowCount)
+	assert.Equal(10, w.ColumnCount)
+	assert.True(w.Attributes.WordWrap)
+	assert.Equal(CEA708Color{2, 2, 2}, w.Pen.Color.Foreground)
+
+	assert.Nil(s.DecodeBlock([]byte("ONE\rTWO\rTHREE")))
+	assert.Equal("TWO\nTHREE", s.String())
+
+	// word wrap, backspace
+	assert.Nil(s.DecodeBlock([]byte("1234567\x08X")))
+	assert.Equal("THREE12345\n6X", s.String())
+
+	// HCR clears the row
+	assert.Nil(s.DecodeBlock([]byte("\x0EHI")))
+	assert.Equal("THREE12345\nHI", s.String())
+
+	// italics for the second word, at column 5 of row 0
+	assert.Nil(s.DecodeBlock([]byte{0x0C, 'A', 0x92, 0x00, 0x05, 0x90, 0x05, 0x80, 'B', 'C'}))
+	normal := cea708PenStyles[1]
+	italics := normal
+	italics.Attributes.Italics = true
+	w = s.Window(1)
+	assert.Equal([]CEA708Span{
+		{Row: 0, Col: 0, Text: "A", Pen: normal},
+		{Row: 0, Col: 5, Text: "BC", Pen: italics},
+	}, w.Spans())
+	assert.Equal("A    BC", s.String())
+	assert.Equal('B', w.Cell(0, 5).Char)
+	assert.Equal(CEA708Cell{}, w.Cell(5, 5))
+
+	// a second window with a higher priority, DF0 hidden, window style 2
+	assert.Nil(s.DecodeBlock([]byte{0x98, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x10, 'T', 'O', 'P'}))
+	assert.Equal("A    BC", s.String())
+	assert.Nil(s.DecodeBlock([]byte{0x8B, 0x03})) // TGW 0, 1
+	assert.Equal("TOP", s.String())
+	assert.Nil(s.DecodeBlock([]byte{0x89, 0x03})) // DSW 0, 1
+	assert.Equal("TOP\nA    BC", s.String())
+	assert.Len(s.VisibleWindows(), 2)
+
+	// CW1, CLW 0, DLW 1
+	assert.Nil(s.DecodeBlock([]byte{0x81, 'D', 0x88, 0x01}))
+	assert.Equal("A    BCD", s.String())
+	assert.Nil(s.DecodeBlock([]byte{0x8C, 0x02, 'E'}))
+	assert.Equal("", s.String())
+	assert.False(s.Window(1).Defined)
+
+	assert.Nil(s.DecodeBlock([]byte{0x8F}))
+	assert.False(s.Window(0).Defined)
+}
+
+func Test_CEA708Decoder(t *testing.T) {
+	assert := assert.New(t)
+
+	// packet: service 1 block with DF0 visible 1x32, "HI", null block
+	packet := []byte{0x06, 0x2A, 0x98, 0x20, 0x00, 0x00, 0x00, 0x1F, 0x00, 'H', 'I', 0x00}
+	cc := []CCData{}
+	for i := 0; i < len(packet); i += 2 {
+		t := CCType_DTVCCData
+		if i == 0 {
+			t = CCType_DTVCCStart
+		}
+		cc = append(cc, CCData{Valid: true, Type: t, Data: uint16(packet[i])<<8 | uint16(packet[i+1])})
+	}
+	ud, err := NewUserData(FrameRate_29_97_NDF, cc)
+	assert.Nil(err)
+
+	d := CEA708Decoder{}
+	services, err := d.DecodeUserData(ud)
+	assert.Nil(err)
+	assert.Equal([]int{1}, services)
+	assert.Equal("HI", d.Service(1).String())
+	assert.Equal("", d.Service(2).String())
+}
+
+func Test_CEA708InvalidWindows(t *testing.T) {
+	assert := assert.New(t)
+
+	s := CEA708Service{}
+	s.Process(CEA708DefineWindow{Window: 0, Visible: true, RowCount: 1, ColumnCount: 4})
+	for _, c := range []CEA708Command{
+		CEA708DefineWindow{Window: 8, RowCount: 1, ColumnCount: 4},
+		CEA708DefineWindow{Window: -1, RowCount: 1, ColumnCount: 4},
+		CEA708DefineWindow{Window: 1, RowCount: 0, ColumnCount: 4},
+		CEA708DefineWindow{Window: 1, RowCount: 1, ColumnCount: 4, WindowStyle: 8},
+		CEA708DefineWindow{Window: 1, RowCount: 1, ColumnCount: 4, PenStyle: -1},
+		CEA708SetCurrentWindow{Window: 8},
+		CEA708SetCurrentWindow{Window: -1},
+	} {
+		s.Process(c)
+	}
+	s.Process(CEA708Text{Text: "OK"})
+	assert.Equal("OK", s.String())
+	assert.False(s.Window(1).Defined)
+	assert.Equal(CEA708Window{}, s.Window(8))
+	assert.Equal(CEA708Window{}, s.Window(-1))
+
+	d := CEA708Decoder{}
+	assert.NotNil(d.Service(63))
+	assert.Nil(d.Service(0))
+	assert.Nil(d.Service(64))
+	assert.Nil(d.Service(-1))
+}
+
+func Test_CEA708WindowLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	window := func(rows, cols int, a CEA708SetWindowAttributes, text string) *CEA708Service {
+		s := &CEA708Service{}
+		s.Process(CEA708DefineWindow{Window: 0, Visible: true, RowCount: rows, ColumnCount: cols})
+		s.Process(a)
+		assert.Nil(s.DecodeBlock([]byte(text)))
+		return s
+	}
+
+	// scroll directions
+	up := CEA708SetWindowAttributes{ScrollDirection: cea708_direction_bottom_to_top}
+	assert.Equal("TWO\nTHREE", window(2, 8, up, "ONE\rTWO\rTHREE").String())
+	down := CEA708SetWindowAttributes{ScrollDirection: cea708_direction_top_to_bottom}
+	s := window(2, 8, down, "ONE\rTWO\rTHREE")
+	assert.Equal("THREE\nTWO", s.String())
+	assert.Equal(0, s.Window(0).Row)
+
+	vertical := CEA708SetWindowAttributes{PrintDirection: cea708_direction_top_to_bottom, ScrollDirection: cea708_direction_right_to_left}
+	s = window(2, 2, vertical, "AB\rCD\rEF")
+	assert.Equal("CE\nDF", s.String())
+	vertical.ScrollDirection = cea708_direction_left_to_right
+	s = window(2, 2, vertical, "AB\rCD\rEF")
+	assert.Equal("EC\nFD", s.String())
+	assert.Equal(0, s.Window(0).Col)
+
+	// right to left print direction starts lines at the right
+	rtl := CEA708SetWindowAttributes{PrintDirection: cea708_direction_right_to_left}
+	s = window(1, 4, rtl, "\x0EAB\x08C")
+	assert.Equal("  CA", s.String())
+
+	// justification
+	left := window(2, 10, CEA708SetWindowAttributes{}, "A B\rCC")
+	assert.Equal("A B\nCC", left.String())
+	right := window(2, 10, CEA708SetWindowAttributes{Justify: cea708_justify_right}, "A B\rCC")
+	assert.Equal("       A B\n        CC", right.String())
+	w := right.Window(0)
+	assert.Equal([]CEA708Span{
+		{Row: 0, Col: 7, Text: "A B", Pen: w.Pen},
+		{Row: 1, Col: 8, Text: "CC", Pen: w.Pen},
+	}, w.Spans())
+	assert.Equal('A', w.Cell(0, 0).Char)
+	center := window(2, 10, CEA708SetWindowAttributes{Justify: cea708_justify_center}, "A B\rCC")
+	assert.Equal("   A B\n    CC", center.String())
+	full := window(2, 10, CEA708SetWindowAttributes{Justify: cea708_justify_full}, "A B C\rCC")
+	assert.Equal("A    B   C\nCC", full.String())
+
+	// word wrap breaks lines between words, and words that fill a line
+	wrap := CEA708SetWindowAttributes{WordWrap: true}
+	assert.Equal("HELLO\nWORLD", window(2, 8, wrap, "HELLO WORLD").String())
+	assert.Equal("A\nBCDEFGHI", window(2, 8, wrap, "A BCDEFGHI").String())
+	assert.Equal("ABCDEFGH\nIJ", window(2, 8, wrap, "ABCDEFGHIJ").String())
+	assert.Equal("ABCDEFGH\nIJ", window(2, 8, wrap, "ABCDEFGH IJ").String())
+	assert.Equal("ONE TWO\nTHREE", window(2, 8, wrap, "ONE TWO\rTHREE").String())
+	assert.Equal("THREE", window(1, 8, wrap, "ONE TWO THREE").String())
+	assert.Equal("ABCDEFG", window(1, 8, wrap, "ABCDEFGH\x08").String())
+
+	// without word wrap the last cell is written over
+	assert.Equal("ABCDEFGJ", window(1, 8, CEA708SetWindowAttributes{}, "ABCDEFGHIJ").String())
+}
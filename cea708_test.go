@@ -27,6 +27,7 @@ package captions
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -50,28 +51,42 @@ func print_uint16(v uint16) string {
 	return fmt.Sprintf("%d", v)
 }
 
-func print_cc_data(cc_data cea708_cc_data) string {
+func print_cc_data(cc_data CCData) string {
 	s := []string{}
-	s = append(s, print_byte(cc_data.marker_bits))
-	s = append(s, print_bool(cc_data.cc_valid))
-	s = append(s, print_byte(byte(cc_data.cc_type)))
-	s = append(s, print_uint16(cc_data.cc_data))
+	s = append(s, print_byte(cc_data.MarkerBits))
+	s = append(s, print_bool(cc_data.Valid))
+	s = append(s, print_byte(byte(cc_data.Type)))
+	s = append(s, print_uint16(cc_data.Data))
 	return strings.Join(s, "|")
 }
 
-func print_user_data(user_data *cea708_user_data) string {
+func print_user_data(user_data *UserData) string {
 	s := []string{}
-	s = append(s, print_bool(user_data.process_em_data_flag))
-	s = append(s, print_bool(user_data.process_cc_data_flag))
-	s = append(s, print_bool(user_data.additional_data_flag))
-	s = append(s, print_byte(user_data.cc_count))
-	s = append(s, print_byte(user_data.em_data))
-	for _, cc := range user_data.cc_data {
+	s = append(s, print_bool(user_data.ProcessEMData))
+	s = append(s, print_bool(user_data.ProcessCCData))
+	s = append(s, print_bool(user_data.AdditionalData))
+	s = append(s, print_byte(user_data.CCCount))
+	s = append(s, print_byte(user_data.EMData))
+	for _, cc := range user_data.CCData {
 		s = append(s, print_cc_data(cc))
 	}
 	return strings.Join(s, ",")
 }
 
+func Test_CEA708ToCCData(t *testing.T) {
+	assert := assert.New(t)
+	// cc_valid is bit 2 of the triplet header, bit 6 is a marker bit
+	cc, err := CEA708ToCCData([]byte{
+		0xB5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03,
+		0x42, 0xFF, // process_cc_data_flag, cc_count 2
+		0xFC, 0x94, 0x20, // field 1
+		0xF8, 0x80, 0x80, // field 1, not valid
+		0xFF,
+	})
+	assert.Nil(err)
+	assert.Equal([]uint16{0x9420}, cc)
+}
+
 func Test_parseCEA708(t *testing.T) {
 	assert := assert.New(t)
 	data, err := os.ReadFile("testdata/sample.cea708")
@@ -87,7 +102,7 @@ func Test_parseCEA708(t *testing.T) {
 	// now check the payloads
 	s := []string{"process_em_data_flag,process_cc_data_flag,additional_data_flag,cc_count,em_data,[]cc_data"}
 	for _, p := range payloads {
-		user_data, err := parseCEA708(p)
+		user_data, err := Parse(p)
 		assert.Nil(err)
 		s = append(s, print_user_data(user_data))
 	}
@@ -102,3 +117,112 @@ func Test_parseCEA708(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(string(expected), str)
 }
+
+func Test_CEA708ToCCPairs(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte{
+		0xB5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03,
+		0x44, 0xFF, // process_cc_data_flag, cc_count 4
+		0xFC, 0x94, 0x20, // field 1
+		0xFD, 0x15, 0x26, // field 2
+		0xF9, 0x80, 0x80, // field 2, not valid
+		0xFF, 0x02, 0x21, // DTVCC packet start
+		0xFF,
+	}
+
+	pairs, err := CEA708ToCCPairs(payload)
+	assert.Nil(err)
+	assert.Equal([]CCData{
+		{MarkerBits: 0x1F, Valid: true, Type: CCType_NTSCField1, Data: 0x9420},
+		{MarkerBits: 0x1F, Valid: true, Type: CCType_NTSCField2, Data: 0x1526},
+		{MarkerBits: 0x1F, Valid: false, Type: CCType_NTSCField2, Data: 0x8080},
+	}, pairs)
+	assert.Equal(1, pairs[0].Field())
+	assert.Equal(2, pairs[1].Field())
+
+	// field 1 only for the legacy API
+	cc, err := CEA708ToCCData(payload)
+	assert.Nil(err)
+	assert.Equal([]uint16{0x9420}, cc)
+}
+
+func Test_CEA708ParserStats(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte{
+		0xB5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03,
+		0x45, 0xFF, // process_cc_data_flag, cc_count 5
+		0xFC, 0x94, 0x20, // field 1
+		0xFF, 0x02, 0x21, // DTVCC packet start
+		0xFD, 0x80, 0x80, // field 2 padding
+		0xFA, 0x00, 0x00, // DTVCC data, not valid
+		0xF8, 0x94, 0x20, // field 1, not valid
+		0xFF,
+	}
+
+	trace := []string{}
+	p := CEA708Parser{Trace: func(s string) { trace = append(trace, s) }}
+	_, err := p.CCPairs(payload)
+	assert.Nil(err)
+
+	// cc_count says 6, but there are only 5
+	short := append([]byte{}, payload...)
+	short[8] = 0x46
+	_, err = p.CCData(short)
+	assert.NotNil(err)
+	_, err = p.CCData([]byte{0xB5})
+	assert.NotNil(err)
+
+	assert.Equal(CEA708Stats{Payloads: 3, Triplets: 5, Padding: 3, ParseErrors: 2, CCCountMismatches: 1}, p.Stats())
+	assert.Equal([]string{
+		"cea708 cc_count 5 em_data 0xFF",
+		"cea708 cc_valid true cc_type 0 0x9420",
+		"cea708 cc_valid true cc_type 3 0x0221",
+		"cea708 cc_valid true cc_type 1 0x8080",
+		"cea708 cc_valid false cc_type 2 0x0000",
+		"cea708 cc_valid false cc_type 0 0x9420",
+		"cea708 error: mismatched cc count",
+		"cea708 error: truncated cea708 payload: payload size",
+	}, trace)
+}
+
+func Test_Parse(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte{
+		0xB5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03,
+		0xC2, 0xFF, // process_em_data_flag, process_cc_data_flag, cc_count 2
+		0xFC, 0x94, 0x20, // field 1
+		0xFA, 0x00, 0x00, // DTVCC data, not valid
+		0xFF,
+	}
+
+	user_data, err := Parse(payload)
+	assert.Nil(err)
+	assert.Equal(&UserData{
+		CountryCode:    0xB5,
+		Provider:       Provider_ATSC,
+		UserIdentifier: 0x47413934,
+		TypeCode:       3,
+		ProcessEMData:  true,
+		ProcessCCData:  true,
+		CCCount:        2,
+		EMData:         0xFF,
+		CCData: []CCData{
+			{MarkerBits: 0x1F, Valid: true, Type: CCType_NTSCField1, Data: 0x9420},
+			{MarkerBits: 0x1F, Valid: false, Type: CCType_DTVCCData, Data: 0x0000},
+		},
+	}, user_data)
+
+	for _, test := range []struct {
+		data []byte
+		err  error
+	}{
+		{payload[:3], ErrCEA708Truncated},
+		{payload[:6], ErrCEA708Truncated},
+		{payload[:8], ErrCEA708Truncated},
+		{payload[:13], ErrCEA708CCCount},
+		{append(append([]byte{}, payload[:7]...), 0x06, 0x00), ErrCEA708UserDataType},
+	} {
+		_, err := Parse(test.data)
+		assert.True(errors.Is(err, test.err), "%v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Timed processing of CEA-708 service commands. DLY suspends the commands of a
+service for up to 25.5 seconds, holding them until the delay expires or a DLC
+arrives, while DLC and RST take effect as soon as they are received.
+*/
+
+import (
+	"time"
+)
+
+// cea708DelayUnit is the unit of DLY
+const cea708DelayUnit = 100 * time.Millisecond
+
+// ProcessAt runs a command received at pts, holding it while a DLY is
+// active. The pts of successive calls must not decrease.
+func (s *CEA708Service) ProcessAt(c CEA708Command, pts time.Duration) {
+	s.Advance(pts)
+	switch c.(type) {
+	case CEA708DelayCancel:
+		s.release(pts)
+	case CEA708Reset:
+		s.Process(c)
+	default:
+		if s.delayed {
+			s.queue = append(s.queue, c)
+			return
+		}
+		s.run(c, pts)
+	}
+}
+
+// DecodeBlockAt runs the commands of the data of a service block received
+// at pts
+func (s *CEA708Service) DecodeBlockAt(data []byte, pts time.Duration) error {
+	commands, err := ParseServiceBlock(data)
+	for _, c := range commands {
+		s.ProcessAt(c, pts)
+	}
+	return err
+}
+
+// Advance runs the commands held by delays that expire by pts, returning
+// true if any delay expired
+func (s *CEA708Service) Advance(pts time.Duration) bool {
+	expired := false
+	for s.delayed && pts >= s.until {
+		s.release(s.until)
+		expired = true
+	}
+	return expired
+}
+
+// Delayed returns true and the time the delay expires if a DLY is holding
+// the commands of the service
+func (s *CEA708Service) Delayed() (time.Duration, bool) {
+	return s.until, s.delayed
+}
+
+// release ends the delay at pts and runs the held commands, up to the next
+// DLY among them
+func (s *CEA708Service) release(pts time.Duration) {
+	queue := s.queue
+	s.delayed, s.queue = false, nil
+	for i, c := range queue {
+		if s.delayed {
+			s.queue = queue[i:]
+			return
+		}
+		s.run(c, pts)
+	}
+}
+
+// run runs a command that is not held, starting a delay for DLY
+func (s *CEA708Service) run(c CEA708Command, pts time.Duration) {
+	if d, ok := c.(CEA708Delay); ok {
+		s.delayed = true
+		s.until = pts + time.Duration(d.Tenths)*cea708DelayUnit
+		return
+	}
+	s.Process(c)
+}
+
+// DecodeAt adds a cc_data triplet received at pts, returning the services
+// that received commands or whose delay expired
+func (d *CEA708Decoder) DecodeAt(cc CCData, pts time.Duration) ([]int, error) {
+	return d.decode(cc, d.Advance(pts), func(s *CEA708Service, data []byte) error {
+		return s.DecodeBlockAt(data, pts)
+	})
+}
+
+// DecodeUserDataAt adds all triplets of a CEA-708 payload received at pts
+func (d *CEA708Decoder) DecodeUserDataAt(ud *UserData, pts time.Duration) ([]int, error) {
+	updated := []int{}
+	var err error
+	for _, cc := range ud.CCData {
+		services, e := d.DecodeAt(cc, pts)
+		if e != nil && err == nil {
+			err = e
+		}
+		updated = append(updated, services...)
+	}
+	return updated, err
+}
+
+// Advance runs the commands held by delays that expire by pts, returning
+// the services whose delay expired
+func (d *CEA708Decoder) Advance(pts time.Duration) []int {
+	expired := []int{}
+	for n := range d.services {
+		if d.services[n].Advance(pts) {
+			expired = append(expired, n)
+		}
+	}
+	return expired
+}
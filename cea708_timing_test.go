@@ -0,0 +1,116 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test_CEA708ServiceDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	s := CEA708Service{}
+	// DF0 visible, 1x10, window style 2, pen style 0
+	assert.Nil(s.DecodeBlockAt([]byte{0x98, 0x20, 0x00, 0x00, 0x00, 0x09, 0x10, 'A'}, 0))
+	assert.Equal("A", s.String())
+
+	// DLY 1.5s holds the text
+	assert.Nil(s.DecodeBlockAt([]byte{0x8D, 15, 'B'}, time.Second))
+	until, delayed := s.Delayed()
+	assert.True(delayed)
+	assert.Equal(2500*time.Millisecond, until)
+	assert.Equal("A", s.String())
+	assert.False(s.Advance(2 * time.Second))
+	assert.Equal("A", s.String())
+	assert.True(s.Advance(2500 * time.Millisecond))
+	assert.Equal("AB", s.String())
+	_, delayed = s.Delayed()
+	assert.False(delayed)
+
+	// a held DLY starts when the first delay expires
+	assert.Nil(s.DecodeBlockAt([]byte{0x8D, 10, 'C', 0x8D, 10, 'D'}, 3*time.Second))
+	s.Advance(4 * time.Second)
+	assert.Equal("ABC", s.String())
+	until, _ = s.Delayed()
+	assert.Equal(5*time.Second, until)
+	s.Advance(10 * time.Second)
+	assert.Equal("ABCD", s.String())
+
+	// both delays expire within one step
+	assert.Nil(s.DecodeBlockAt([]byte{0x8D, 1, 'E', 0x8D, 1, 'F'}, 11*time.Second))
+	assert.True(s.Advance(12 * time.Second))
+	assert.Equal("ABCDEF", s.String())
+
+	// DLC releases the held commands at once
+	assert.Nil(s.DecodeBlockAt([]byte{0x8D, 50, 'G'}, 13*time.Second))
+	assert.Equal("ABCDEF", s.String())
+	assert.Nil(s.DecodeBlockAt([]byte{0x8E}, 13*time.Second))
+	assert.Equal("ABCDEFG", s.String())
+
+	// RST drops the held commands
+	assert.Nil(s.DecodeBlockAt([]byte{0x8D, 50, 0x88, 0x01}, 14*time.Second))
+	assert.Equal("ABCDEFG", s.String())
+	s.ProcessAt(CEA708Reset{}, 14*time.Second)
+	_, delayed = s.Delayed()
+	assert.False(delayed)
+	assert.False(s.Advance(time.Minute))
+	assert.Equal("", s.String())
+}
+
+func Test_CEA708DecoderDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	// packet: service 1 block with DF0 visible 1x10, DLY 1s, "HI", null block
+	packet := []byte{0x07, 0x2B, 0x98, 0x20, 0x00, 0x00, 0x00, 0x09, 0x10, 0x8D, 0x0A, 'H', 'I', 0x00}
+	cc := []CCData{}
+	for i := 0; i < len(packet); i += 2 {
+		t := CCType_DTVCCData
+		if i == 0 {
+			t = CCType_DTVCCStart
+		}
+		cc = append(cc, CCData{Valid: true, Type: t, Data: uint16(packet[i])<<8 | uint16(packet[i+1])})
+	}
+	ud, err := NewUserData(FrameRate_29_97_NDF, cc)
+	assert.Nil(err)
+
+	d := CEA708Decoder{}
+	services, err := d.DecodeUserDataAt(ud, time.Second)
+	assert.Nil(err)
+	assert.Equal([]int{1}, services)
+	assert.Equal("", d.Service(1).String())
+	assert.Equal([]int{}, d.Advance(1500*time.Millisecond))
+
+	// the delay expires before the next payload is decoded
+	ud, err = NewUserData(FrameRate_29_97_NDF, nil)
+	assert.Nil(err)
+	services, err = d.DecodeUserDataAt(ud, 2*time.Second)
+	assert.Nil(err)
+	assert.Equal([]int{1}, services)
+	assert.Equal("HI", d.Service(1).String())
+}
@@ -0,0 +1,172 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Writer for CEA-708 caption frames, the reverse of Parse.
+
+References: https://www.itu.int/rec/T-REC-H.264
+						 https://www.itu.int/rec/T-REC-H.265
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	cea708_country_code_usa = 0xB5
+	cea708_user_identifier  = 0x47413934 // "GA94"
+	cea708_marker_bits      = 0x1F
+	// SEI payload type of user_data_registered_itu_t_t35
+	sei_type_user_data_registered_itu_t_t35 = 4
+)
+
+// NewUserData returns ATSC user data carrying the triplets, padded with
+// invalid DTVCC triplets to the cc_count of the frame rate. The marker bits
+// of the triplets are set.
+func NewUserData(rate FrameRate, cc []CCData) (*UserData, error) {
+	if !rate.valid() {
+		return nil, errors.New("invalid frame rate")
+	}
+	count := int(frameRates[rate].ccCount)
+	if len(cc) > count {
+		return nil, errors.New("too many cc_data triplets for the frame rate")
+	}
+	ud := UserData{
+		CountryCode:    cea708_country_code_usa,
+		Provider:       Provider_ATSC,
+		UserIdentifier: cea708_user_identifier,
+		TypeCode:       3,
+		ProcessCCData:  true,
+		CCCount:        byte(count),
+		EMData:         0xFF,
+		CCData:         make([]CCData, 0, count),
+	}
+	for _, cd := range cc {
+		cd.MarkerBits = cea708_marker_bits
+		ud.CCData = append(ud.CCData, cd)
+	}
+	for len(ud.CCData) < count {
+		ud.CCData = append(ud.CCData, CCData{MarkerBits: cea708_marker_bits, Type: CCType_DTVCCData})
+	}
+	return &ud, nil
+}
+
+// Marshal returns the H.264 SEI payload of "Registered User Data ITU-T T.35"
+// for the user data. Fields are written as they are, except for cc_count
+// which is the number of triplets.
+func (ud *UserData) Marshal() ([]byte, error) {
+	if ud.Provider != Provider_ATSC && ud.Provider != Provider_DirectTV {
+		return nil, errors.New("unsupported cea708 provider")
+	}
+	if ud.TypeCode != 3 {
+		return nil, ErrCEA708UserDataType
+	}
+	if len(ud.CCData) > 0x1F {
+		return nil, errors.New("too many cc_data triplets")
+	}
+
+	data := []byte{byte(ud.CountryCode)}
+	if ud.CountryCode == 0xFF {
+		data = append(data, ud.CountryCodeExtension)
+	}
+	data = append(data, byte(ud.Provider>>8), byte(ud.Provider))
+	if ud.Provider == Provider_ATSC {
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], ud.UserIdentifier)
+	}
+	data = append(data, byte(ud.TypeCode))
+
+	flags := byte(len(ud.CCData))
+	if ud.ProcessEMData {
+		flags |= 0x80
+	}
+	if ud.ProcessCCData {
+		flags |= 0x40
+	}
+	if ud.AdditionalData {
+		flags |= 0x20
+	}
+	data = append(data, flags, ud.EMData)
+	for _, cd := range ud.CCData {
+		b := cd.MarkerBits<<3 | byte(cd.Type)&0x03
+		if cd.Valid {
+			b |= 0x04
+		}
+		data = append(data, b, byte(cd.Data>>8), byte(cd.Data))
+	}
+	return append(data, 0xFF), nil // marker_bits
+}
+
+// H264SEI returns a H.264 SEI NAL unit, without start code, carrying a
+// "Registered User Data ITU-T T.35" payload such as one from Marshal.
+func H264SEI(payload []byte) []byte {
+	return seiNAL([]byte{0x06}, payload)
+}
+
+// HEVCSEI returns a HEVC prefix SEI NAL unit, without start code, carrying
+// a "Registered User Data ITU-T T.35" payload such as one from Marshal.
+func HEVCSEI(payload []byte) []byte {
+	return seiNAL([]byte{39 << 1, 0x01}, payload) // nuh_temporal_id_plus1 1
+}
+
+func seiNAL(header, payload []byte) []byte {
+	rbsp := seiSize(nil, sei_type_user_data_registered_itu_t_t35)
+	rbsp = seiSize(rbsp, len(payload))
+	rbsp = append(rbsp, payload...)
+	rbsp = append(rbsp, 0x80) // rbsp_trailing_bits
+	return append(header, emulationPrevention(rbsp)...)
+}
+
+// seiSize appends a SEI payload type or size, coded as a run of 0xFF bytes
+// and the remainder
+func seiSize(data []byte, n int) []byte {
+	for ; n >= 0xFF; n -= 0xFF {
+		data = append(data, 0xFF)
+	}
+	return append(data, byte(n))
+}
+
+// emulationPrevention inserts 0x03 after two zero bytes that are followed by
+// a byte of 0x03 or less, so the NAL payload has no start codes.
+func emulationPrevention(rbsp []byte) []byte {
+	data := make([]byte, 0, len(rbsp)+len(rbsp)/2)
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 0x03 {
+			data = append(data, 0x03)
+			zeros = 0
+		}
+		data = append(data, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return data
+}
@@ -0,0 +1,110 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test_NewUserData(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := []CCData{
+		{Valid: true, Type: CCType_NTSCField1, Data: 0x9420},
+		{Valid: true, Type: CCType_NTSCField2, Data: 0x8080},
+	}
+	for rate, count := range map[FrameRate]int{
+		FrameRate_23_976: 25, FrameRate_25: 24, FrameRate_29_97_DF: 20,
+		FrameRate_29_97_NDF: 20, FrameRate_50: 12, FrameRate_59_94: 10,
+	} {
+		ud, err := NewUserData(rate, cc)
+		assert.Nil(err)
+		assert.Len(ud.CCData, count)
+
+		payload, err := ud.Marshal()
+		assert.Nil(err)
+		assert.Len(payload, 10+3*count+1)
+		parsed, err := Parse(payload)
+		assert.Nil(err)
+		assert.Equal(ud, parsed)
+
+		pairs, err := CEA708ToCCPairs(payload)
+		assert.Nil(err)
+		assert.Equal([]CCData{
+			{MarkerBits: 0x1F, Valid: true, Type: CCType_NTSCField1, Data: 0x9420},
+			{MarkerBits: 0x1F, Valid: true, Type: CCType_NTSCField2, Data: 0x8080},
+		}, pairs)
+	}
+
+	_, err := NewUserData(FrameRate_59_94, make([]CCData, 11))
+	assert.NotNil(err)
+	_, err = NewUserData(FrameRate_59_94+1, cc)
+	assert.NotNil(err)
+}
+
+func Test_Marshal(t *testing.T) {
+	assert := assert.New(t)
+
+	ud, err := NewUserData(FrameRate_59_94, []CCData{{Valid: true, Type: CCType_NTSCField1, Data: 0x9420}})
+	assert.Nil(err)
+	payload, err := ud.Marshal()
+	assert.Nil(err)
+	assert.Equal([]byte{
+		0xB5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03,
+		0x4A, 0xFF,
+		0xFC, 0x94, 0x20,
+		0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00,
+		0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00,
+		0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00, 0xFA, 0x00, 0x00,
+		0xFF,
+	}, payload)
+
+	// DirecTV has no user identifier
+	ud = &UserData{CountryCode: 0xB5, Provider: Provider_DirectTV, TypeCode: 3, CCData: []CCData{{MarkerBits: 0x1F, Type: CCType_NTSCField2, Data: 0x8080}}}
+	payload, err = ud.Marshal()
+	assert.Nil(err)
+	assert.Equal([]byte{0xB5, 0x00, 0x2F, 0x03, 0x01, 0x00, 0xF9, 0x80, 0x80, 0xFF}, payload)
+
+	_, err = (&UserData{Provider: Provider_ATSC, TypeCode: 6}).Marshal()
+	assert.Equal(ErrCEA708UserDataType, err)
+	_, err = (&UserData{Provider: 1, TypeCode: 3}).Marshal()
+	assert.NotNil(err)
+}
+
+func Test_SEI(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := []byte{0xB5, 0x00, 0x00, 0x01, 0x00, 0x00}
+	assert.Equal([]byte{0x06, 0x04, 0x06, 0xB5, 0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x80}, H264SEI(payload))
+	assert.Equal([]byte{0x4E, 0x01, 0x04, 0x06, 0xB5, 0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x80}, HEVCSEI(payload))
+
+	// sizes of 255 and more take extra bytes
+	sei := H264SEI(make([]byte, 300))
+	assert.Equal([]byte{0x06, 0x04, 0xFF, 0x2D, 0x00, 0x00, 0x03}, sei[:7])
+	assert.Equal(byte(0x80), sei[len(sei)-1])
+}
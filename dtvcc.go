@@ -0,0 +1,161 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Assembler for DTVCC caption channel packets, the CEA-708 data carried in
+cc_data triplets of type DTVCCStart and DTVCCData.
+
+References: https://shop.cta.tech/products/digital-television-dtv-closed-captioning
+*/
+
+import "errors"
+
+// ErrDTVCCBlockTruncated is returned when a service block runs past the end
+// of its packet
+var ErrDTVCCBlockTruncated = errors.New("truncated dtvcc service block")
+
+// DTVCCPacket is a DTVCC caption channel packet
+type DTVCCPacket struct {
+	// sequence_number, 0-3
+	Sequence int
+	// The packet data after the header, made of service blocks
+	Data []byte
+	// The sequence number does not follow the one of the packet before, so
+	// packets were lost
+	Gap bool
+	// The packet ended before packet_size bytes were received
+	Truncated bool
+}
+
+// DTVCCServiceBlock is the data of a packet for a single caption service
+type DTVCCServiceBlock struct {
+	// 1-6, or the extended service number 7-63
+	Service int
+	Data    []byte
+}
+
+// DTVCCAssembler collects cc_data triplets into DTVCC packets. The zero
+// value is ready to use.
+type DTVCCAssembler struct {
+	packet []byte
+	// packet_size of the packet being collected, 0 if there is none
+	size int
+	// sequence number of the last packet, if one was started
+	sequence int
+	started  bool
+}
+
+// Add adds a triplet, returning the packets it completed. Triplets that are not
+// valid DTVCC data are ignored. A packet cut short by the start of the next
+// one is returned with Truncated set.
+func (a *DTVCCAssembler) Add(cc CCData) []DTVCCPacket {
+	if !cc.Valid {
+		return nil
+	}
+	packets := []DTVCCPacket{}
+	switch cc.Type {
+	case CCType_DTVCCStart:
+		if a.size > 0 {
+			packets = append(packets, a.finish())
+		}
+		header := byte(cc.Data >> 8)
+		a.size = 2 * int(header&0x3F)
+		if a.size == 0 {
+			a.size = 128
+		}
+		a.packet = append(a.packet[:0], header, byte(cc.Data))
+	case CCType_DTVCCData:
+		if a.size == 0 {
+			return nil // joined mid packet, or padding after it
+		}
+		a.packet = append(a.packet, byte(cc.Data>>8), byte(cc.Data))
+	default:
+		return nil
+	}
+	if a.size > 0 && len(a.packet) >= a.size {
+		packets = append(packets, a.finish())
+	}
+	return packets
+}
+
+// AddUserData adds all triplets of a CEA-708 payload
+func (a *DTVCCAssembler) AddUserData(ud *UserData) []DTVCCPacket {
+	packets := []DTVCCPacket{}
+	for _, cc := range ud.CCData {
+		packets = append(packets, a.Add(cc)...)
+	}
+	return packets
+}
+
+// Flush returns the packet being collected, if any, as truncated
+func (a *DTVCCAssembler) Flush() []DTVCCPacket {
+	if a.size == 0 {
+		return nil
+	}
+	return []DTVCCPacket{a.finish()}
+}
+
+func (a *DTVCCAssembler) finish() DTVCCPacket {
+	p := DTVCCPacket{
+		Sequence:  int(a.packet[0] >> 6),
+		Truncated: len(a.packet) < a.size,
+	}
+	if len(a.packet) > a.size {
+		a.packet = a.packet[:a.size]
+	}
+	p.Data = append([]byte{}, a.packet[1:]...)
+	p.Gap = a.started && p.Sequence != (a.sequence+1)&0x03
+	a.sequence, a.started, a.size = p.Sequence, true, 0
+	return p
+}
+
+// ServiceBlocks splits the packet into its service blocks, stopping at the
+// null block header that fills the rest of the packet. The blocks before an
+// error are returned.
+func (p *DTVCCPacket) ServiceBlocks() ([]DTVCCServiceBlock, error) {
+	blocks := []DTVCCServiceBlock{}
+	for i := 0; i < len(p.Data); {
+		service, size := int(p.Data[i]>>5), int(p.Data[i]&0x1F)
+		i++
+		if service == 0 {
+			break // null block header
+		}
+		if service == 7 && size != 0 {
+			if i >= len(p.Data) {
+				return blocks, ErrDTVCCBlockTruncated
+			}
+			service = int(p.Data[i] & 0x3F)
+			i++
+		}
+		if i+size > len(p.Data) {
+			return blocks, ErrDTVCCBlockTruncated
+		}
+		blocks = append(blocks, DTVCCServiceBlock{Service: service, Data: p.Data[i : i+size]})
+		i += size
+	}
+	return blocks, nil
+}
@@ -0,0 +1,87 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func dtvcc(t CCType, data uint16) CCData {
+	return CCData{MarkerBits: 0x1F, Valid: true, Type: t, Data: data}
+}
+
+func Test_DTVCCAssembler(t *testing.T) {
+	assert := assert.New(t)
+
+	a := DTVCCAssembler{}
+	// sequence 0, 6 bytes: service 1 with 3 bytes, null block header
+	assert.Empty(a.Add(dtvcc(CCType_DTVCCStart, 0x0323)))
+	assert.Empty(a.Add(CCData{Type: CCType_DTVCCData})) // padding
+	assert.Empty(a.Add(dtvcc(CCType_NTSCField1, 0x9420)))
+	assert.Empty(a.Add(dtvcc(CCType_DTVCCData, 0x4142)))
+	packets := a.Add(dtvcc(CCType_DTVCCData, 0x4300))
+	assert.Equal([]DTVCCPacket{{Sequence: 0, Data: []byte{0x23, 0x41, 0x42, 0x43, 0x00}}}, packets)
+
+	blocks, err := packets[0].ServiceBlocks()
+	assert.Nil(err)
+	assert.Equal([]DTVCCServiceBlock{{Service: 1, Data: []byte{0x41, 0x42, 0x43}}}, blocks)
+
+	// data after the packet is complete is ignored
+	assert.Empty(a.Add(dtvcc(CCType_DTVCCData, 0x4444)))
+
+	// sequence 2 skips 1, and is cut short by sequence 3
+	assert.Empty(a.Add(dtvcc(CCType_DTVCCStart, 0x8322)))
+	assert.Equal([]DTVCCPacket{{Sequence: 2, Data: []byte{0x22}, Gap: true, Truncated: true}}, a.Add(dtvcc(CCType_DTVCCStart, 0xC2E1)))
+	assert.Equal([]DTVCCPacket{{Sequence: 3, Data: []byte{0xE1}, Truncated: true}}, a.Flush())
+	assert.Empty(a.Flush())
+
+	// a 2 byte packet is complete with its first triplet
+	assert.Equal([]DTVCCPacket{{Sequence: 0, Data: []byte{0x00}}}, a.Add(dtvcc(CCType_DTVCCStart, 0x0100)))
+
+	// size code 0 is 128 bytes, extended service 7-63
+	packets = a.AddUserData(&UserData{CCData: []CCData{dtvcc(CCType_DTVCCStart, 0x40E2)}})
+	assert.Empty(packets)
+	data := []CCData{dtvcc(CCType_DTVCCData, 0x2A41)}
+	for i := 0; i < 62; i++ {
+		data = append(data, dtvcc(CCType_DTVCCData, 0x4242))
+	}
+	packets = a.AddUserData(&UserData{CCData: data})
+	assert.Len(packets, 1)
+	assert.Len(packets[0].Data, 127)
+	assert.False(packets[0].Gap)
+
+	blocks, err = packets[0].ServiceBlocks()
+	assert.Nil(err)
+	assert.Len(blocks, 42)
+	assert.Equal(DTVCCServiceBlock{Service: 42, Data: []byte{0x41, 0x42}}, blocks[0])
+	assert.Equal(DTVCCServiceBlock{Service: 2, Data: []byte{0x42, 0x42}}, blocks[1])
+
+	blocks, err = (&DTVCCPacket{Data: []byte{0x22, 0x41, 0x42, 0x23, 0x43}}).ServiceBlocks()
+	assert.Equal(ErrDTVCCBlockTruncated, err)
+	assert.Equal([]DTVCCServiceBlock{{Service: 1, Data: []byte{0x41, 0x42}}}, blocks)
+}
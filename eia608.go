@@ -34,6 +34,8 @@ References: https://shop.cta.tech/products/line-21-data-services
 */
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 )
 
@@ -49,8 +51,42 @@ const (
 	Mode608_Unknown Mode608 = iota
 	Mode608_PopOn
 	Mode608_PaintOn
+	Mode608_RollUp
+	Mode608_Text
 )
 
+// Event608 identifies what a decoded 608 packet did to a channel.
+type Event608 int
+
+const (
+	// The displayed memory changed, e.g. a paint-on or roll-up character
+	Event608_DisplayUpdated Event608 = iota
+	// EDM erased the displayed memory
+	Event608_DisplayCleared
+	// EOC swapped memories, replacing the displayed caption
+	Event608_CaptionCommitted
+	// CR rolled up a roll-up caption
+	Event608_RollUp
+	// ENM erased the non-displayed memory
+	Event608_NonDisplayedCleared
+	// The channel switched to Mode
+	Event608_ModeChanged
+	// Alarm on or off, see Alarm
+	Event608_Alarm
+	// The text service (T1-T4) sharing the channel changed
+	Event608_TextUpdated
+)
+
+// EIA608Event is reported for every change a 608 packet makes to a channel.
+type EIA608Event struct {
+	Type    Event608
+	Channel Channel608
+	// New mode, for Event608_ModeChanged
+	Mode Mode608
+	// For Event608_Alarm
+	Alarm bool
+}
+
 // represents a snapshot of the current 608 state.
 type EIA608State struct {
 	Mode Mode608
@@ -60,95 +96,533 @@ type EIA608State struct {
 	Row int
 	// Configured column position (not current cursor position)
 	Col int
+	// Current cursor position, row 1-15 from the top as in EIA608Span
+	CursorRow int
+	CursorCol int
+	// Attributes applied to the next character written
+	Pen EIA608Pen
 	// The captions themselves.
 	Content string
 }
 
+// EIA608Pen holds the 608 character attributes.
+type EIA608Pen struct {
+	Color      Color608
+	Background Color608
+	Opacity    Opacity608
+	Italics    bool
+	Underline  bool
+	Flash      bool
+}
+
+// Color608 is a 608 foreground color
+type Color608 int
+
+const (
+	Color608_White Color608 = iota
+	Color608_Green
+	Color608_Blue
+	Color608_Cyan
+	Color608_Red
+	Color608_Yellow
+	Color608_Magenta
+	Color608_Black
+)
+
+// Opacity608 is the opacity of a 608 background
+type Opacity608 int
+
+const (
+	Opacity608_Opaque Opacity608 = iota
+	Opacity608_SemiTransparent
+	Opacity608_Transparent
+)
+
+// EIA608Span is a run of adjacent characters on a row sharing the same style.
+type EIA608Span struct {
+	// Row 1-15 from the top, column 0-31 from the left
+	Row, Col   int
+	Text       string
+	Color      Color608
+	Background Color608
+	Opacity    Opacity608
+	Italics    bool
+	Underline  bool
+	Flash      bool
+}
+
+type CellKind608 int
+
+const (
+	CellKind608_Empty CellKind608 = iota
+	CellKind608_Space             // standard or transparent space
+	CellKind608_Char
+)
+
+// EIA608Cell is a single character cell of the 608 display.
+// Attributes are only set for non empty cells.
+type EIA608Cell struct {
+	Kind       CellKind608
+	Char       rune
+	Color      Color608
+	Background Color608
+	Opacity    Opacity608
+	Italics    bool
+	Underline  bool
+	Flash      bool
+}
+
+// EIA608Grid is the 15x32 608 display. Unlike the internal buffers, row 0 is
+// the top row, so grid[r][c] is row r+1 as used by EIA608Span.
+type EIA608Grid [Rows][Cols]EIA608Cell
+
+// Channel608 identifies one of the four 608 caption channels. CC1 and CC2 are
+// carried in field 1, CC3 and CC4 in field 2.
+type Channel608 int
+
+const (
+	Channel608_CC1 Channel608 = iota
+	Channel608_CC2
+	Channel608_CC3
+	Channel608_CC4
+)
+
+// Parity608 selects what the decoder does with a pair that fails the parity check
+type Parity608 int
+
+const (
+	// Parity608_Drop skips the pair
+	Parity608_Drop Parity608 = iota
+	// Parity608_Replace shows a solid block (0x7F) for each bad character, as
+	// recommended by CEA-608. Control codes are still skipped, the redundant
+	// copy takes their place.
+	Parity608_Replace
+	// Parity608_Error skips the pair and returns an EIA608ParityError
+	Parity608_Error
+)
+
+// EIA608ParityError is returned for pairs with a parity error when Parity is
+// Parity608_Error.
+type EIA608ParityError struct {
+	Field  int
+	CCData uint16
+}
+
+func (e EIA608ParityError) Error() string {
+	return fmt.Sprintf("608 parity error in field %d pair 0x%04X", e.Field, e.CCData)
+}
+
+// EIA608Stats counts what the decoder has seen
+type EIA608Stats struct {
+	// pairs passed to the decoder, including padding
+	Pairs   int
+	Padding int
+	// redundant copies of control codes that were skipped
+	Duplicates int
+	// control codes, PACs (PAC), mid-row codes (MRC) and background
+	// attributes (BG) decoded, by their CEA-608 abbreviation
+	Commands map[string]int
+	// times a pair was for a different channel than the one before it on
+	// the same field
+	ChannelSwitches int
+	ModeChanges     int
+	// pop-on captions displayed and roll-up lines scrolled
+	Captions int
+
+	// pairs that failed the parity check
+	ParityErrors int
+	// pairs that are not a valid code or character
+	UnknownCodes int
+	// valid control codes the decoder does not act on, like the optional
+	// character set selection codes
+	UnsupportedCommands int
+}
+
 // EIA608Frame is an opaque type holding information about 608 frames.
 type EIA608Frame struct {
-	// State
-	// Does every channel have its own state? If so, move this to the frameBuffer struct
-	underline bool
-	style     byte
-	row, col  uint
-	ccData    uint16
-
-	// TODO add CC1-4 buffers
+	// LenientDuplicates skips a control code or special character whenever it
+	// repeats the last code decoded on its field, even after padding or a
+	// dropped copy. By default only the copy sent in the very next pair is
+	// skipped, as CEA-608 specifies, so codes sent four times act twice.
+	LenientDuplicates bool
+	// Parity selects how pairs with a parity error are handled
+	Parity Parity608
+	// Trace, if set, is called with a description of every decoded code
+	Trace func(string)
+
+	stats EIA608Stats
+
+	// last pair seen on each field, used to skip duplicate control commands
+	ccData [2]uint16
+	// data channel bit (0 or 1) of the last control code on each field.
+	// Plain text has no channel bit, so it goes to whichever channel the
+	// last control code selected, starting with CC1 on field 1 and CC3 on
+	// field 2.
+	channelBit [2]Channel608
+	last       Channel608
+	// inside an XDS packet on field 2
+	xds bool
+	// events of the last decoded packet
+	events []EIA608Event
+
+	channels [4]eia608Channel
+	// text services T1-T4 share the data channel with CC1-CC4
+	texts    [4]eia608Channel
+	textMode [4]bool
+}
+
+// eia608Channel holds the decoding state of a single caption or text channel.
+type eia608Channel struct {
+	// text services scroll a single buffer and ignore row positioning
+	text bool
+	// events of the packet being decoded, without the channel set
+	events []EIA608Event
+
 	front  frameBuffer
 	back   frameBuffer
 	active *frameBuffer
 }
 
-// Decode a single, 2-byte 608 packet. This accumulates data into a frame.
+// Decode a single, 2-byte 608 packet from field 1. This accumulates data into a frame.
 // If the frame is ready for display, returns true. Otherwise, false or error.
 func (f *EIA608Frame) Decode(ccData uint16) (bool, error) {
-	// parity error, just skip it
+	return f.DecodeField(1, ccData)
+}
+
+// DecodeField decodes a single, 2-byte 608 packet from the given field (1 or 2).
+// Returns true if the channel the packet was addressed to is ready for display.
+// Use Channel to find out which channel that was.
+func (f *EIA608Frame) DecodeField(field int, ccData uint16) (bool, error) {
+	if field != 1 && field != 2 {
+		return false, errors.New("invalid 608 field")
+	}
+	field--
+	f.events = nil
+	f.stats.Pairs++
+
 	if parityWord(ccData) != ccData {
-		return false, nil
+		f.stats.ParityErrors++
+		f.tracef("field %d parity error 0x%04X", field+1, ccData)
+		var ok bool
+		if ccData, ok = f.replaceParity(ccData); !ok {
+			f.forget(field)
+			if f.Parity == Parity608_Error {
+				return false, EIA608ParityError{Field: field + 1, CCData: ccData}
+			}
+			return false, nil
+		}
 	}
 
 	ccData &= 0x7F7F // strip off parity bits
 	if ccData == 0 {
+		f.stats.Padding++
+		f.forget(field)
 		return false, nil // padding
 	}
 
+	// XDS data looks like text, so skip everything from an XDS code
+	// until a caption control code takes the field back.
+	if field == 1 && IsXDS(ccData) {
+		f.xds = true
+		f.forget(field)
+		return false, nil
+	}
+	if field == 1 && f.xds {
+		if !isChannelCode(ccData) {
+			f.forget(field)
+			return false, nil
+		}
+		f.xds = false
+	}
+
 	// skip duplicate control commands.
-	if (isSpecialNA(ccData) || isControl(ccData)) && ccData == f.ccData {
+	if f.duplicate(field, ccData) {
+		f.stats.Duplicates++
 		return false, nil
 	}
 
-	f.ccData = ccData
+	f.ccData[field] = ccData
+	f.count(ccData)
+	if isChannelCode(ccData) {
+		bit := Channel608((0x0800 & ccData) >> 11)
+		if f.channelBit[field] != bit {
+			f.stats.ChannelSwitches++
+		}
+		f.channelBit[field] = bit
+	}
+	f.last = f.fieldChannel(field)
+	mode := f.mode(f.last)
+	text := f.isText(f.last, ccData)
+	f.trace(f.last, text, ccData)
+	if text {
+		// text never makes the captions ready for display
+		_, err := f.texts[f.last].decode(ccData)
+		f.collectEvents(f.last, &f.texts[f.last], mode)
+		return false, err
+	}
+	ready, err := f.channels[f.last].decode(ccData)
+	f.collectEvents(f.last, &f.channels[f.last], mode)
+	return ready, err
+}
+
+// fieldChannel returns the channel plain text on field (0 or 1) goes to
+func (f *EIA608Frame) fieldChannel(field int) Channel608 {
+	return Channel608(2*field) + f.channelBit[field]
+}
+
+// replaceParity returns ccData with the characters that failed the parity
+// check replaced by a solid block, or false if the pair should be skipped.
+func (f *EIA608Frame) replaceParity(ccData uint16) (uint16, bool) {
+	hi, lo := byte(ccData>>8), byte(ccData)
+	// control codes, XDS and padding can not be guessed
+	if f.Parity != Parity608_Replace || 0x20 > 0x7F&hi {
+		return ccData, false
+	}
+	if parityByte(hi) != hi {
+		hi = parityByte(0x7F)
+	}
+	if parityByte(lo) != lo {
+		lo = parityByte(0x7F)
+	}
+	return uint16(hi)<<8 | uint16(lo), true
+}
+
+// Stats returns what was counted since the decoder was created
+func (f *EIA608Frame) Stats() EIA608Stats {
+	s := f.stats
+	s.Commands = map[string]int{}
+	for k, v := range f.stats.Commands {
+		s.Commands[k] = v
+	}
+	return s
+}
+
+// count records the type of a code
+func (f *EIA608Frame) count(ccData uint16) {
+	if name := commandName(ccData); name != "" {
+		if f.stats.Commands == nil {
+			f.stats.Commands = map[string]int{}
+		}
+		f.stats.Commands[name]++
+	}
+	switch {
+	case isControl(ccData):
+		if isUnsupported(ccData) {
+			f.stats.UnsupportedCommands++
+		}
+	case isPreamble(ccData), isMidRowChange(ccData), isBackground(ccData),
+		isBasicNA(ccData), isSpecialNA(ccData), isWesternEu(ccData):
+	default:
+		f.stats.UnknownCodes++
+	}
+}
+
+// duplicate returns true if ccData is the redundant copy of a control code
+func (f *EIA608Frame) duplicate(field int, ccData uint16) bool {
+	if f.LenientDuplicates {
+		return (isSpecialNA(ccData) || isControl(ccData)) && ccData == f.ccData[field]
+	}
+	// every code in the 0x10-0x1F range is sent twice, text is not
+	if isChannelCode(ccData) && ccData == f.ccData[field] {
+		f.ccData[field] = 0 // a third copy is a new code
+		return true
+	}
+	return false
+}
+
+// forget a pair that was not decoded, so a control code following it is not a
+// duplicate
+func (f *EIA608Frame) forget(field int) {
+	if !f.LenientDuplicates {
+		f.ccData[field] = 0
+	}
+}
+
+// DecodeEvents decodes a single, 2-byte 608 packet from field 1 and returns
+// everything it changed.
+func (f *EIA608Frame) DecodeEvents(ccData uint16) ([]EIA608Event, error) {
+	return f.DecodeFieldEvents(1, ccData)
+}
+
+// DecodeFieldEvents decodes a single, 2-byte 608 packet from the given field (1 or 2)
+// and returns everything it changed.
+func (f *EIA608Frame) DecodeFieldEvents(field int, ccData uint16) ([]EIA608Event, error) {
+	_, err := f.DecodeField(field, ccData)
+	return f.events, err
+}
+
+func (f *EIA608Frame) mode(ch Channel608) Mode608 {
+	if f.textMode[ch] {
+		return f.texts[ch].mode()
+	}
+	return f.channels[ch].mode()
+}
+
+// collectEvents moves the events of c to the frame, adding mode changes and
+// display updates that were not reported more specifically.
+func (f *EIA608Frame) collectEvents(ch Channel608, c *eia608Channel, mode Mode608) {
+	if m := f.mode(ch); m != mode {
+		f.stats.ModeChanges++
+		f.events = append(f.events, EIA608Event{Type: Event608_ModeChanged, Channel: ch, Mode: m})
+	}
+	updated := c.front.dirty
+	for _, e := range c.events {
+		switch e.Type {
+		case Event608_CaptionCommitted, Event608_RollUp:
+			f.stats.Captions++
+			updated = false
+		case Event608_DisplayCleared:
+			updated = false
+		}
+		e.Channel = ch
+		f.events = append(f.events, e)
+	}
+	if updated {
+		e := EIA608Event{Type: Event608_DisplayUpdated, Channel: ch}
+		if c.text {
+			e.Type = Event608_TextUpdated
+		}
+		f.events = append(f.events, e)
+	}
+	c.events, c.front.dirty, c.back.dirty = nil, false, false
+}
+
+func (c *eia608Channel) emit(t Event608) {
+	c.events = append(c.events, EIA608Event{Type: t})
+}
+
+func (c *eia608Channel) mode() Mode608 {
+	switch c.active {
+	case nil:
+		return Mode608_Unknown
+	case &c.back:
+		return Mode608_PopOn
+	}
+	return c.front.mode()
+}
+
+// isText returns true if ccData belongs to the text service of the channel,
+// switching between caption and text mode as needed.
+func (f *EIA608Frame) isText(ch Channel608, ccData uint16) bool {
+	if !isControl(ccData) {
+		return f.textMode[ch]
+	}
+	switch controlCommand(ccData) {
+	case eia608_control_text_restart, eia608_control_text_resume_text_display:
+		f.textMode[ch] = true
+		f.texts[ch].text = true
+	case eia608_control_resume_caption_loading, eia608_control_resume_direct_captioning,
+		eia608_control_roll_up_2, eia608_control_roll_up_3, eia608_control_roll_up_4,
+		eia608_control_end_of_caption:
+		f.textMode[ch] = false
+	case eia608_control_erase_display_memory, eia608_control_erase_non_displayed_memory:
+		// caption memory commands, the mode does not change
+		return false
+	}
+	return f.textMode[ch]
+}
+
+func (c *eia608Channel) decode(ccData uint16) (bool, error) {
 	if isControl(ccData) {
-		return f.parseControl(ccData), nil
+		return c.parseControl(ccData), nil
 	}
-	if f.active == nil {
+	if c.active == nil {
 		// We joined an in-progress stream, We must wait for a control character to tell us what mode we are in
 		return false, nil
 	}
 
 	if isPreamble(ccData) {
-		return false, f.parsePreamble(ccData)
+		if c.text {
+			return false, nil
+		}
+		return false, c.parsePreamble(ccData)
 	}
 	if isMidRowChange(ccData) {
-		return false, f.parseMidRowChange(ccData)
+		return false, c.parseMidRowChange(ccData)
+	}
+	if isBackground(ccData) {
+		return false, c.parseBackground(ccData)
 	}
 	if isBasicNA(ccData) || isSpecialNA(ccData) || isWesternEu(ccData) {
-		if err := f.parseText(ccData); err != nil {
+		if err := c.parseText(ccData); err != nil {
 			return false, err
 		}
-		return f.active.state.Rollup > 0, nil
+		return c.active.state.Rollup > 0, nil
 	}
 	return false, nil // TODO error here?
 }
 
-// String returns the front (display) buffer of the eia608 frame as a string
+// Channel returns the channel addressed by the most recently decoded packet.
+func (f *EIA608Frame) Channel() Channel608 {
+	return f.last
+}
+
+// String returns the front (display) buffer of CC1 as a string
 func (f *EIA608Frame) String() string {
-	return f.front.String()
+	return f.ChannelString(Channel608_CC1)
+}
+
+// ChannelString returns the front (display) buffer of the given channel as a string
+func (f *EIA608Frame) ChannelString(ch Channel608) string {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 {
+		return ""
+	}
+	return f.channels[ch].front.String()
+}
+
+// Spans returns the front (display) buffer of CC1 as styled spans, top row first
+func (f *EIA608Frame) Spans() []EIA608Span {
+	return f.ChannelSpans(Channel608_CC1)
+}
+
+// ChannelSpans returns the front (display) buffer of the given channel as styled spans, top row first
+func (f *EIA608Frame) ChannelSpans(ch Channel608) []EIA608Span {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 {
+		return nil
+	}
+	return f.channels[ch].front.spans()
+}
+
+// DisplayedGrid returns every cell of the front (display) buffer of the given channel
+func (f *EIA608Frame) DisplayedGrid(ch Channel608) *EIA608Grid {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 {
+		return nil
+	}
+	return f.channels[ch].front.grid()
+}
+
+// NonDisplayedGrid returns every cell of the back (non-displayed) buffer of the given channel
+func (f *EIA608Frame) NonDisplayedGrid(ch Channel608) *EIA608Grid {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 {
+		return nil
+	}
+	return f.channels[ch].back.grid()
+}
+
+// TextString returns the text service sharing the data channel with ch as a
+// string, e.g. T2 for Channel608_CC2. Rows are oldest first.
+func (f *EIA608Frame) TextString(ch Channel608) string {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 {
+		return ""
+	}
+	return f.texts[ch].front.String()
 }
 
-// Represents a snapshot of the 608 state for the front (display) buffer.
+// Represents a snapshot of the 608 state for the front (display) buffer of CC1.
 func (f *EIA608Frame) StateSnapshot() *EIA608State {
+	return f.ChannelStateSnapshot(Channel608_CC1)
+}
+
+// Represents a snapshot of the 608 state for the front (display) buffer of the given channel.
+func (f *EIA608Frame) ChannelStateSnapshot(ch Channel608) *EIA608State {
 	// unknown mode if active has not yet been set
-	if f.active == nil {
+	if ch < Channel608_CC1 || ch > Channel608_CC4 || f.channels[ch].active == nil {
 		return &EIA608State{
 			Mode: Mode608_Unknown,
 		}
 	}
-	frameBuffer := f.front
-	mode := Mode608_PopOn
-	if frameBuffer.state.Rollup > 0 {
-		// for now. may need to add a non-paint on rollup mode later
-		mode = Mode608_PaintOn
-	}
-	return &EIA608State{
-		Mode:   mode,
-		Rollup: frameBuffer.state.Rollup,
-		// TODO rows might need to accommodate current cursor row (newlines)?
-		//      rows are not currently used in practice but check if this changes
-		Row:     Rows - frameBuffer.state.Row,
-		Col:     frameBuffer.state.Col,
-		Content: frameBuffer.String(),
-	}
+	state := f.channels[ch].front.snapshotState()
+	return &state
 }
 
 var parityTable = func() [128]byte {
@@ -209,131 +683,179 @@ const (
 	eia608_control_carriage_return            = 0x142D
 	eia608_control_erase_non_displayed_memory = 0x142E
 	eia608_control_end_of_caption             = 0x142F
+	eia608_control_flash_on                   = 0x1428
 
 	eia608_tab_offset_1 = 0x1721
 	eia608_tab_offset_2 = 0x1722
 	eia608_tab_offset_3 = 0x1723
+
+	eia608_background_transparent     = 0x172D
+	eia608_foreground_black           = 0x172E
+	eia608_foreground_black_underline = 0x172F
 )
 
 func isControl(ccData uint16) bool { return 0x1420 == (0x7670&ccData) || 0x1720 == (0x7770&ccData) }
 
-func (f *EIA608Frame) backspace() {
-	if f.col > 0 {
-		f.col--
+// isUnsupported returns true for the control codes parseControl ignores
+func isUnsupported(ccData uint16) bool {
+	cmd := controlCommand(ccData)
+	return 0x1720 == cmd || (0x1724 <= cmd && 0x172C >= cmd)
+}
+
+// control codes, PACs, mid-row codes and special characters carry the data channel bit (0x0800)
+func isChannelCode(ccData uint16) bool { return 0x1000 == (0x7000 & ccData) }
+
+func (c *eia608Channel) backspace() {
+	if c.active.col > 0 {
+		c.active.col--
 	}
-	f.active.setChar(f.row, f.col, frameBufferChar{})
+	c.active.setChar(c.active.row, c.active.col, frameBufferChar{})
 }
 
-func (f *EIA608Frame) parseControl(ccData uint16) bool {
-	var cmd uint16
+// controlCommand strips the channel and field bits from a control code
+func controlCommand(ccData uint16) uint16 {
 	if 0 == 0x0200&ccData {
-		//cc = (ccData&0x0800)>>10 | (ccData&0x0100)>>8 // TODO cc channel
-		cmd = 0x167F & ccData
-	} else {
-		//cc = (ccData & 0x0800) >> 11 // TODO cc channel
-		cmd = 0x177F & ccData
+		return 0x167F & ccData
 	}
+	return 0x177F & ccData
+}
 
+func (c *eia608Channel) parseControl(ccData uint16) bool {
+	// the channel bit has already been used to route ccData to this channel
+	cmd := controlCommand(ccData)
 	switch cmd {
 	// Switch to paint on
 	case eia608_control_resume_direct_captioning:
-		f.active = &f.front
-		f.active.state.Rollup = 1
+		c.active = &c.front
+		c.active.state.Rollup = 1
 		return false //LIBCAPTION_OK;
 
 	case eia608_control_erase_display_memory:
-		f.front.clear()
+		c.front.clear()
+		c.emit(Event608_DisplayCleared)
 		return true //LIBCAPTION_READY;
 
 		// ROLL-UP
 	case eia608_control_roll_up_2:
-		f.active = &f.front
-		f.active.state.Rollup = 2
+		c.active = &c.front
+		c.active.state.Rollup = 2
 		return false //LIBCAPTION_OK
 
 	case eia608_control_roll_up_3:
-		f.active = &f.front
-		f.active.state.Rollup = 3
+		c.active = &c.front
+		c.active.state.Rollup = 3
 		return false //LIBCAPTION_OK
 
 	case eia608_control_roll_up_4:
-		f.active = &f.front
-		f.active.state.Rollup = 4
+		c.active = &c.front
+		c.active.state.Rollup = 4
 		return false //LIBCAPTION_OK
 
 	case eia608_control_carriage_return:
-		if f.active == nil {
+		if c.active == nil {
 			return false
 		}
-		f.col = 0
-		f.active.carriageReturn(f.row)
-		f.active.state.Col = 0
+		c.active.col, c.active.pen.background = 0, eia608_background_default
+		c.active.carriageReturn(c.active.row)
+		c.active.state.Col = 0
+		if c.active == &c.front && c.active.mode() == Mode608_RollUp {
+			c.emit(Event608_RollUp)
+		}
 		return false //LIBCAPTION_OK
 	case eia608_control_backspace:
-		if f.active == nil {
+		if c.active == nil {
 			return false
 		}
-		f.backspace()
+		c.backspace()
 		return false //LIBCAPTION_OK
 	case eia608_control_delete_to_end_of_row:
-		if f.active == nil {
+		if c.active == nil {
 			return false
 		}
-		for i := f.col; i < Cols; i++ {
-			f.active.setChar(f.row, i, frameBufferChar{})
+		for i := c.active.col; i < Cols; i++ {
+			c.active.setChar(c.active.row, i, frameBufferChar{})
 		}
 		return false //LIBCAPTION_OK
 
 	// POP ON
 	case eia608_control_resume_caption_loading:
-		f.active = &f.back
-		f.active.state.Rollup = 0
+		c.active = &c.back
+		c.active.state.Rollup = 0
 		return false //LIBCAPTION_OK;
 
 	case eia608_control_erase_non_displayed_memory:
-		f.back.clear()
+		c.back.clear()
+		c.emit(Event608_NonDisplayedCleared)
 		return false //LIBCAPTION_OK;
 
 	case eia608_control_end_of_caption:
-		f.front, f.back = f.back, f.front
-		f.back.clearState()
-		// TODO hoist cursors (f.col, f.row) into the state struct
-		f.col, f.row = 0, 0
-		f.active = &f.back
+		c.front, c.back = c.back, c.front
+		c.back.clearState()
+		c.active = &c.back
+		c.emit(Event608_CaptionCommitted)
 		return true //LIBCAPTION_READY
 
 	// cursor positioning
-	case eia608_tab_offset_1:
-		if f.active == nil {
+	case eia608_tab_offset_1, eia608_tab_offset_2, eia608_tab_offset_3:
+		if c.active == nil {
 			return false
 		}
-		// TODO ideally f.col (current cursor position) would be within state itself
-		f.col += 1
-		f.active.state.Col += 1
+		// tab offsets never move the cursor past the last column
+		c.active.col += uint(cmd - eia608_tab_offset_1 + 1)
+		if c.active.col > Cols-1 {
+			c.active.col = Cols - 1
+		}
+		c.active.state.Col = int(c.active.col)
 		return false //LIBCAPTION_OK;
-	case eia608_tab_offset_2:
-		if f.active == nil {
+
+	// attributes, these are sent after a standard space which they replace
+	case eia608_background_transparent:
+		if c.active == nil {
 			return false
 		}
-		// TODO ideally f.col (current cursor position) would be within state itself
-		f.col += 2
-		f.active.state.Col += 2
-		return false //LIBCAPTION_OK;
-	case eia608_tab_offset_3:
-		if f.active == nil {
+		c.active.pen.background = eia608_background_none
+		c.backspace()
+		c.writeChar(0)
+		return false //LIBCAPTION_OK
+	case eia608_foreground_black, eia608_foreground_black_underline:
+		if c.active == nil {
 			return false
 		}
-		// TODO ideally f.col (current cursor position) would be within state itself
-		f.col += 3
-		f.active.state.Col += 3
-		return false //LIBCAPTION_OK;
+		c.active.pen.style, c.active.pen.flash = eia608_style_black, false
+		c.active.pen.underline = 0x0001&ccData == 1
+		c.backspace()
+		c.writeChar(0)
+		return false //LIBCAPTION_OK
+
+	case eia608_control_flash_on:
+		if c.active == nil {
+			return false
+		}
+		c.active.pen.flash = true
+		// flash on is a spacing attribute like the mid-row codes
+		c.writeChar(0)
+		return false //LIBCAPTION_OK
+
+	// TEXT
+	case eia608_control_text_restart:
+		c.active = &c.front
+		c.active.clearState()
+		c.active.state.Rollup = Rows
+		return false //LIBCAPTION_OK
+
+	case eia608_control_text_resume_text_display:
+		if c.active == nil {
+			c.active = &c.front
+			c.active.state.Rollup = Rows
+		}
+		return false //LIBCAPTION_OK
+
+	case eia608_control_alarm_off, eia608_control_alarm_on:
+		c.events = append(c.events, EIA608Event{Type: Event608_Alarm, Alarm: controlCommand(ccData) == eia608_control_alarm_on})
+		return false //LIBCAPTION_OK
 
 	// Unhandled
 	default:
-		// case eia608_control_alarm_off:
-		// case eia608_control_alarm_on:
-		// case eia608_control_text_restart:
-		// case eia608_control_text_resume_text_display:
 		return false //LIBCAPTION_OK
 
 	}
@@ -348,89 +870,107 @@ const (
 	eia608_style_yellow  = 5
 	eia608_style_magenta = 6
 	eia608_style_italics = 7
+	eia608_style_black   = 8
+
+	// cell backgrounds. Otherwise 1 + the low nibble of the background attribute code
+	eia608_background_default = 0 // black opaque
+	eia608_background_none    = 0x11
 )
 
 func isPreamble(ccData uint16) bool { return 0x1040 == (0x7040 & ccData) }
-func (f *EIA608Frame) parsePreamble(ccData uint16) error {
-	f.row = rowMap[((0x0700&ccData)>>7)|((0x0020&ccData)>>5)]
-	// cc := !!(0x0800 & ccData) // TODO handle channels!
-	f.underline = 0x0001&ccData == 1
+func (c *eia608Channel) parsePreamble(ccData uint16) error {
+	c.active.row = rowMap[((0x0700&ccData)>>7)|((0x0020&ccData)>>5)]
+	c.active.pen.underline = 0x0001&ccData == 1
 
-	f.col, f.style = 0, eia608_style_white
+	c.active.col, c.active.pen.style, c.active.pen.flash, c.active.pen.background = 0, eia608_style_white, false, eia608_background_default
 	if 0x0010&ccData == 0 {
-		f.style = byte((0x000E & ccData) >> 1)
+		c.active.pen.style = byte((0x000E & ccData) >> 1)
 	} else {
-		f.col = uint(4 * ((0x000E & ccData) >> 1))
+		c.active.col = uint(4 * ((0x000E & ccData) >> 1))
 	}
-	f.active.state.Row = int(f.row)
-	f.active.state.Col = int(f.col)
+	c.active.state.Row = int(c.active.row)
+	c.active.state.Col = int(c.active.col)
 	return nil
 }
 
 func isMidRowChange(ccData uint16) bool { return 0x1120 == (0x7770 & ccData) }
-func (f *EIA608Frame) parseMidRowChange(ccData uint16) error {
-	// cc := !!(0x0800 & ccData); TODO cc channel
+func (c *eia608Channel) parseMidRowChange(ccData uint16) error {
 	if 0x1120 == (0x7770 & ccData) {
-		f.style = byte((0x000E & ccData) >> 1)
-		f.underline = 0x0001&ccData == 1
+		c.active.pen.style = byte((0x000E & ccData) >> 1)
+		c.active.pen.underline = 0x0001&ccData == 1
+		c.active.pen.flash = false
 	}
+	// mid-row codes are spacing attributes (47 CFR 15.119): they show as a
+	// space with the new style
+	c.writeChar(0)
+	return nil
+}
+
+func isBackground(ccData uint16) bool { return 0x1020 == (0x7770 & ccData) }
+func (c *eia608Channel) parseBackground(ccData uint16) error {
+	c.active.pen.background = byte(0x000F&ccData) + 1
+	c.backspace()
+	c.writeChar(0)
 	return nil
 }
 
 // returns true if the buffer changed
-func (f *EIA608Frame) writeChar(i uint16) bool {
+func (c *eia608Channel) writeChar(i uint16) bool {
 	char := '�'
 	if int(i) < len(charMap) {
 		char = charMap[i]
 	}
-	r := f.active.setChar(f.row, f.col, frameBufferChar{
-		char:      char,
-		underline: f.underline,
-		style:     f.style,
-	})
-	if f.col < Cols {
-		f.col++
+	pen := c.active.pen
+	pen.char = char
+	if i == eia608_transparent_space {
+		pen.background = eia608_background_none
+	}
+	r := c.active.setChar(c.active.row, c.active.col, pen)
+	if c.active.col < Cols {
+		c.active.col++
 	}
 	return r
 }
 
+// index of the transparent space in charMap
+const eia608_transparent_space = 0x69
+
 func isBasicNA(ccData uint16) bool   { return 0x0000 != (0x6000 & ccData) }
 func isSpecialNA(ccData uint16) bool { return 0x1130 == (0x7770 & ccData) }
 func isWesternEu(ccData uint16) bool { return 0x1220 == (0x7660 & ccData) }
 
-func (f *EIA608Frame) parseText(ccData uint16) error {
+func (c *eia608Channel) parseText(ccData uint16) error {
 	// Handle Basic NA BEFORE we strip the channel bit
 	if isBasicNA(ccData) {
-		f.writeChar((ccData >> 8) - 0x20)
+		c.writeChar((ccData >> 8) - 0x20)
 		ccData &= 0x00FF
 		if 0x0020 <= ccData && 0x0080 > ccData {
 			// we got first char, yes. But what about second char?
-			f.writeChar(ccData - 0x20)
+			c.writeChar(ccData - 0x20)
 		}
 		return nil
 	}
 
-	// Check then strip second channel toggle
-	// ccToggle := ccData & 0x0800 // TODO CC1-4
+	// Strip second channel toggle
 	ccData = ccData & 0xF7FF
 	if isSpecialNA(ccData) {
 		// Special North American character
-		f.writeChar(ccData - 0x1130 + 0x60)
+		c.writeChar(ccData - 0x1130 + 0x60)
 		return nil
 	}
 
 	if 0x1220 <= ccData && 0x1240 > ccData {
 		// Extended Western European character set, Spanish/Miscellaneous/French
-		f.backspace()
-		f.writeChar(ccData - 0x1220 + 0x70)
+		c.backspace()
+		c.writeChar(ccData - 0x1220 + 0x70)
 		return nil
 
 	}
 
 	if 0x1320 <= ccData && 0x1340 > ccData {
 		// Extended Western European character set, Portuguese/German/Danish
-		f.backspace()
-		f.writeChar(ccData - 0x1320 + 0x90)
+		c.backspace()
+		c.writeChar(ccData - 0x1320 + 0x90)
 		return nil
 	}
 
@@ -438,26 +978,35 @@ func (f *EIA608Frame) parseText(ccData uint16) error {
 }
 
 type frameBufferChar struct {
-	underline bool
-	style     byte
-	char      rune
+	underline  bool
+	flash      bool
+	style      byte
+	background byte
+	char       rune
 }
 
 type frameBufferRow [Cols]frameBufferChar
 
 type frameBuffer struct {
 	state EIA608State
-	data  [Rows]frameBufferRow
+	// set whenever data changes
+	dirty bool
+	// cursor position and pen attributes, the char of pen is unused
+	row, col uint
+	pen      frameBufferChar
+	data     [Rows]frameBufferRow
 }
 
 func (b *frameBuffer) clear() {
 	b.data = [Rows]frameBufferRow{}
+	b.dirty = true
 }
 
 func (b *frameBuffer) clearState() {
 	b.clear()
 	b.state.Row = 0
 	b.state.Col = 0
+	b.row, b.col = 0, 0
 }
 
 func (b *frameBuffer) getChar(r, c uint) *frameBufferChar {
@@ -478,12 +1027,14 @@ func (b *frameBuffer) carriageReturn(row uint) {
 		b.data[idx] = b.data[idx-1]
 	}
 	b.data[row] = [Cols]frameBufferChar{} // clear last row
+	b.dirty = true
 }
 
 func (b *frameBuffer) setChar(r, c uint, char frameBufferChar) bool {
 	val := b.getChar(r, c)
 	if val != nil && *val != char {
 		*val = char
+		b.dirty = true
 		return true
 	}
 	return false
@@ -513,3 +1064,159 @@ func (b *frameBuffer) String() string {
 
 	return strings.Join(s, "\n")
 }
+
+func (c frameBufferChar) attributes() EIA608Pen {
+	p := EIA608Pen{Underline: c.underline, Flash: c.flash}
+	switch c.style {
+	case eia608_style_italics:
+		p.Italics = true
+	case eia608_style_black:
+		p.Color = Color608_Black
+	default:
+		p.Color = Color608(c.style)
+	}
+	p.Background, p.Opacity = c.backgroundColor()
+	return p
+}
+
+// char is the reverse of attributes
+func (p EIA608Pen) char(r rune) frameBufferChar {
+	c := frameBufferChar{char: r, underline: p.Underline, flash: p.Flash, style: byte(p.Color)}
+	if p.Italics {
+		c.style = eia608_style_italics
+	} else if p.Color == Color608_Black {
+		c.style = eia608_style_black
+	}
+	switch {
+	case p.Opacity == Opacity608_Transparent:
+		c.background = eia608_background_none
+	case p.Background == Color608_Black && p.Opacity == Opacity608_Opaque:
+		c.background = eia608_background_default
+	default:
+		c.background = byte(p.Background)<<1 + 1
+		if p.Opacity == Opacity608_SemiTransparent {
+			c.background++
+		}
+	}
+	return c
+}
+
+func (c frameBufferChar) cell() EIA608Cell {
+	if c.char == 0 {
+		return EIA608Cell{}
+	}
+	p := c.attributes()
+	cell := EIA608Cell{
+		Kind:       CellKind608_Char,
+		Char:       c.char,
+		Color:      p.Color,
+		Background: p.Background,
+		Opacity:    p.Opacity,
+		Italics:    p.Italics,
+		Underline:  p.Underline,
+		Flash:      p.Flash,
+	}
+	if c.char == ' ' {
+		cell.Kind = CellKind608_Space
+	}
+	return cell
+}
+
+// span returns an empty span starting with the style of c
+func (c frameBufferChar) span(row, col int) EIA608Span {
+	p := c.attributes()
+	return EIA608Span{
+		Row:        row,
+		Col:        col,
+		Color:      p.Color,
+		Background: p.Background,
+		Opacity:    p.Opacity,
+		Italics:    p.Italics,
+		Underline:  p.Underline,
+		Flash:      p.Flash,
+	}
+}
+
+func (c frameBufferChar) backgroundColor() (Color608, Opacity608) {
+	switch c.background {
+	case eia608_background_default:
+		return Color608_Black, Opacity608_Opaque
+	case eia608_background_none:
+		return Color608_Black, Opacity608_Transparent
+	}
+	code := c.background - 1
+	if code&0x01 == 0x01 {
+		return Color608(code >> 1), Opacity608_SemiTransparent
+	}
+	return Color608(code >> 1), Opacity608_Opaque
+}
+
+func (b *frameBuffer) grid() *EIA608Grid {
+	g := &EIA608Grid{}
+	for r := range b.data {
+		for c, char := range b.data[r] {
+			// we have the bottom first
+			g[Rows-1-r][c] = char.cell()
+		}
+	}
+	return g
+}
+
+func (b *frameBuffer) spans() []EIA608Span {
+	var spans []EIA608Span
+	// top row first, we have the bottom first
+	for r := Rows - 1; r >= 0; r-- {
+		var sb strings.Builder
+		var span EIA608Span
+		var last frameBufferChar
+		flush := func() {
+			if sb.Len() > 0 {
+				span.Text = sb.String()
+				spans = append(spans, span)
+				sb.Reset()
+			}
+		}
+		for c, char := range b.data[r] {
+			if char.char == 0 {
+				flush()
+				continue
+			}
+			if sb.Len() == 0 || char.style != last.style || char.underline != last.underline ||
+				char.flash != last.flash || char.background != last.background {
+				flush()
+				span = char.span(Rows-r, c)
+			}
+			sb.WriteRune(char.char)
+			last = char
+		}
+		flush()
+	}
+	return spans
+}
+
+func (b *frameBuffer) mode() Mode608 {
+	switch b.state.Rollup {
+	case 0:
+		return Mode608_PopOn
+	case 1:
+		return Mode608_PaintOn
+	case Rows:
+		return Mode608_Text
+	}
+	return Mode608_RollUp
+}
+
+func (b *frameBuffer) snapshotState() EIA608State {
+	return EIA608State{
+		Mode:   b.mode(),
+		Rollup: b.state.Rollup,
+		// TODO rows might need to accommodate current cursor row (newlines)?
+		//      rows are not currently used in practice but check if this changes
+		Row:       Rows - b.state.Row,
+		Col:       b.state.Col,
+		CursorRow: Rows - int(b.row),
+		CursorCol: int(b.col),
+		Pen:       b.pen.attributes(),
+		Content:   b.String(),
+	}
+}
@@ -0,0 +1,167 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"time"
+)
+
+// EIA608Cue is a caption displayed on a channel from Start until End.
+type EIA608Cue struct {
+	Channel Channel608
+	Start   time.Duration
+	End     time.Duration
+	Text    string
+	Spans   []EIA608Span
+}
+
+type eia608OpenCue struct {
+	open  bool
+	start time.Duration
+	text  string
+	spans []EIA608Span
+	// paint-on only, the display changed since it last settled
+	dirty   bool
+	changed time.Duration
+}
+
+// EIA608CueDecoder decodes timestamped 608 pairs into timed cues.
+//
+// Pop-on captions last from their EOC to the next EOC or EDM. Roll-up captions
+// start when a line settles with a CR and last until the next CR or EDM.
+// Paint-on captions start when the last character was painted, once a
+// following control code settles the display.
+type EIA608CueDecoder struct {
+	Frame EIA608Frame
+	cues  [4]eia608OpenCue
+}
+
+// Decode a single, 2-byte 608 packet from field 1 presented at pts.
+// Returns the cues that ended with this packet.
+func (d *EIA608CueDecoder) Decode(ccData uint16, pts time.Duration) ([]EIA608Cue, error) {
+	return d.DecodeField(1, ccData, pts)
+}
+
+// DecodeField decodes a single, 2-byte 608 packet from the given field (1 or 2)
+// presented at pts. Returns the cues that ended with this packet.
+func (d *EIA608CueDecoder) DecodeField(field int, ccData uint16, pts time.Duration) ([]EIA608Cue, error) {
+	var cues []EIA608Cue
+
+	// control codes that end painting settle paint-on captions. They carry
+	// their channel, so this can be done before the code changes the display
+	if raw := ccData & 0x7F7F; field >= 1 && field <= 2 && parityWord(ccData) == ccData && settles(raw) {
+		ch := Channel608(2*(field-1)) + Channel608((0x0800&raw)>>11)
+		if d.paintOn(ch) {
+			cues = d.settle(cues, ch)
+		}
+	}
+
+	events, err := d.Frame.DecodeFieldEvents(field, ccData)
+	if err != nil {
+		return cues, err
+	}
+	for _, e := range events {
+		switch e.Type {
+		case Event608_CaptionCommitted, Event608_RollUp:
+			cues = d.close(cues, e.Channel, pts)
+			d.open(e.Channel, pts)
+		case Event608_DisplayCleared:
+			cues = d.close(cues, e.Channel, pts)
+		case Event608_DisplayUpdated:
+			if d.paintOn(e.Channel) {
+				d.cues[e.Channel].dirty, d.cues[e.Channel].changed = true, pts
+			}
+		}
+	}
+	return cues, nil
+}
+
+// Flush ends all open cues at pts, e.g. at the end of the stream.
+func (d *EIA608CueDecoder) Flush(pts time.Duration) []EIA608Cue {
+	var cues []EIA608Cue
+	for ch := range d.cues {
+		if d.paintOn(Channel608(ch)) {
+			cues = d.settle(cues, Channel608(ch))
+		}
+		cues = d.close(cues, Channel608(ch), pts)
+	}
+	return cues
+}
+
+// settles returns true for the control codes that end painting a caption:
+// mode changes, erasing the display, carriage returns and EOC. Characters,
+// PACs, mid-row codes and edits such as backspace are part of painting.
+func settles(ccData uint16) bool {
+	if !isControl(ccData) {
+		return false
+	}
+	switch controlCommand(ccData) {
+	case eia608_control_resume_caption_loading,
+		eia608_control_roll_up_2, eia608_control_roll_up_3, eia608_control_roll_up_4,
+		eia608_control_resume_direct_captioning,
+		eia608_control_erase_display_memory,
+		eia608_control_carriage_return,
+		eia608_control_end_of_caption:
+		return true
+	}
+	return false
+}
+
+func (d *EIA608CueDecoder) paintOn(ch Channel608) bool {
+	c := &d.Frame.channels[ch]
+	return !d.Frame.textMode[ch] && c.active == &c.front && c.front.state.Rollup == 1
+}
+
+// settle replaces the open cue of a paint-on channel if its display changed
+func (d *EIA608CueDecoder) settle(cues []EIA608Cue, ch Channel608) []EIA608Cue {
+	c := &d.cues[ch]
+	if !c.dirty {
+		return cues
+	}
+	c.dirty = false
+	if d.Frame.channels[ch].front.String() == c.text {
+		return cues
+	}
+	cues = d.close(cues, ch, c.changed)
+	d.open(ch, c.changed)
+	return cues
+}
+
+func (d *EIA608CueDecoder) open(ch Channel608, pts time.Duration) {
+	front := &d.Frame.channels[ch].front
+	c := &d.cues[ch]
+	c.start, c.text, c.spans = pts, front.String(), front.spans()
+	c.open = c.text != ""
+}
+
+func (d *EIA608CueDecoder) close(cues []EIA608Cue, ch Channel608, pts time.Duration) []EIA608Cue {
+	c := &d.cues[ch]
+	if c.open && pts > c.start {
+		cues = append(cues, EIA608Cue{Channel: ch, Start: c.start, End: pts, Text: c.text, Spans: c.spans})
+	}
+	c.open, c.text, c.spans = false, "", nil
+	return cues
+}
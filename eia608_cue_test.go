@@ -0,0 +1,131 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func decodeCues(t *testing.T, d *EIA608CueDecoder, start time.Duration, cc ...uint16) ([]EIA608Cue, time.Duration) {
+	// one pair per frame at 30fps
+	cues := []EIA608Cue{}
+	pts := start
+	for _, c := range cc {
+		out, err := d.Decode(parityWord(c), pts)
+		assert.Nil(t, err)
+		cues = append(cues, out...)
+		pts += time.Second / 30
+	}
+	return cues, pts
+}
+
+func cueText(cues []EIA608Cue) []string {
+	s := []string{}
+	for _, c := range cues {
+		s = append(s, c.Text)
+	}
+	return s
+}
+
+func Test608_PopOnCues(t *testing.T) {
+	assert := assert.New(t)
+
+	d := EIA608CueDecoder{}
+	cues, _ := decodeCues(t, &d, 0,
+		0x1420, 0x1420, 0x1470, 0x1470, 0x4849, 0x142F, 0x142F, // "HI" at frame 5
+	)
+	assert.Empty(cues)
+	cues, _ = decodeCues(t, &d, 2*time.Second,
+		0x1420, 0x1420, 0x1470, 0x1470, 0x4F4B, 0x142F, 0x142F, // "OK" at 2s + frame 5
+	)
+	hi := time.Second / 30 * 5
+	ok := 2*time.Second + hi
+	assert.Equal([]EIA608Cue{{
+		Channel: Channel608_CC1,
+		Start:   hi,
+		End:     ok,
+		Text:    "HI",
+		Spans:   []EIA608Span{{Row: 15, Text: "HI", Background: Color608_Black}},
+	}}, cues)
+
+	cues, _ = decodeCues(t, &d, 3*time.Second, 0x142C, 0x142C) // EDM
+	assert.Len(cues, 1)
+	assert.Equal("OK", cues[0].Text)
+	assert.Equal(ok, cues[0].Start)
+	assert.Equal(3*time.Second, cues[0].End)
+	assert.Empty(d.Flush(4 * time.Second))
+}
+
+func Test608_RollUpCues(t *testing.T) {
+	assert := assert.New(t)
+
+	d := EIA608CueDecoder{}
+	cues, _ := decodeCues(t, &d, 0,
+		0x1425, 0x1425, 0x1470, 0x1470, 0x4F4E, 0x4500, // RU2, "ONE"
+		0x142D, 0x142D, 0x5457, 0x4F00, // CR, "TWO"
+		0x142D, 0x142D, 0x5448, 0x5245, 0x4500, // CR, "THREE"
+	)
+	// the first line only shows once it settles with the first CR
+	assert.Equal([]string{"ONE"}, cueText(cues))
+	assert.Equal(time.Second/30*6, cues[0].Start)
+	assert.Equal(time.Second/30*10, cues[0].End)
+
+	cues = d.Flush(time.Second)
+	// RU2 keeps two rows, so ONE has rolled off
+	assert.Equal([]string{"TWO"}, cueText(cues))
+	assert.Equal(time.Second/30*10, cues[0].Start)
+	assert.Equal(time.Second, cues[0].End)
+}
+
+func Test608_PaintOnCues(t *testing.T) {
+	assert := assert.New(t)
+
+	d := EIA608CueDecoder{}
+	cues, pts := decodeCues(t, &d, 0,
+		0x1429, 0x1429, 0x1470, 0x1470, 0x4849, // RDC, "HI" painted at frame 4
+		0x1450, 0x1450, 0x4F4B, // row 14, "OK" painted at frame 7
+	)
+	assert.Empty(cues)
+	cues, _ = decodeCues(t, &d, pts+time.Second, 0x142C, 0x142C) // EDM
+	assert.Equal([]string{"OK\nHI"}, cueText(cues))
+	assert.Equal(time.Second/30*7, cues[0].Start)
+	assert.Equal(pts+time.Second, cues[0].End)
+
+	// special and extended characters are painted like text
+	d = EIA608CueDecoder{}
+	cues, pts = decodeCues(t, &d, 0,
+		0x1429, 0x1429, 0x1470, 0x1470, 0x4849, // RDC, "HI"
+		0x1137, 0x1137, 0x4120, // "♪", "A "
+		0x4500, 0x1221, 0x1221, // "E", "É" replaces it, painted at frame 9
+	)
+	assert.Empty(cues)
+	cues, _ = decodeCues(t, &d, pts, 0x142C, 0x142C) // EDM
+	assert.Equal([]string{"HI♪A É"}, cueText(cues))
+	assert.Equal(time.Second/30*9, cues[0].Start)
+}
@@ -0,0 +1,360 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Encoder for EIA / CEA-608 captions.
+
+References: https://shop.cta.tech/products/line-21-data-services
+*/
+
+import (
+	"errors"
+	"fmt"
+)
+
+// charIndex is the reverse of charMap
+var charIndex = func() map[rune]uint16 {
+	m := map[rune]uint16{}
+	for i, r := range charMap {
+		if _, ok := m[r]; !ok {
+			m[r] = uint16(i)
+		}
+	}
+	return m
+}()
+
+// basic characters shown by decoders that do not support the extended
+// characters, indexed by charMap index - 0x70. The extended character
+// replaces it on decoders that do.
+var extendedFallback = []byte("" +
+	// Extended Spanish/Miscellaneous
+	"AEOUUu'!.'-cs.\"\"" +
+	// Extended French
+	"AACEEEeIIiOUuU\"\"" +
+	// Portuguese
+	"AaIIiOoOo[]/'-!-" +
+	// German/Danish
+	"AaOosY.!AaOo++++")
+
+// EIA608Encoder turns caption text into 608 byte pairs. Pairs for CC3 and CC4
+// must be sent in field 2.
+//
+// Styles are set with PACs, mid-row codes, black foreground and flash on. All
+// but the PAC take up a column and show as a space, so they replace leading
+// spaces of a span or go in the columns before it. A span with no room for
+// them is an error. Background and opacity are not encoded.
+type EIA608Encoder struct {
+	Channel Channel608
+}
+
+// PopOn returns the pairs, with parity, of a pop-on caption made of the given
+// spans: RCL, ENM, the text and EOC. Control codes are sent twice.
+func (e *EIA608Encoder) PopOn(spans []EIA608Span) ([]uint16, error) {
+	w, err := e.writer()
+	if err != nil {
+		return nil, err
+	}
+	w.control(eia608_control_resume_caption_loading)
+	w.control(eia608_control_erase_non_displayed_memory)
+	if err := w.spans(spans); err != nil {
+		return nil, err
+	}
+	w.control(eia608_control_end_of_caption)
+	return w.pairs, nil
+}
+
+// RollUp returns the pairs, with parity, that scroll a roll-up caption of 2, 3
+// or 4 rows up by one line and write the spans on its base row: RUx, CR, the
+// base row PAC and the text. The spans must all be on the base row, which
+// must have room for the rows above it. The base row should not change between
+// lines, CR scrolls the rows above the row of the previous line.
+func (e *EIA608Encoder) RollUp(rows int, spans []EIA608Span) ([]uint16, error) {
+	w, err := e.writer()
+	if err != nil {
+		return nil, err
+	}
+	if rows < 2 || rows > 4 {
+		return nil, errors.New("invalid 608 roll-up rows")
+	}
+	for i := range spans {
+		if spans[i].Row != spans[0].Row || spans[i].Row < rows {
+			return nil, errors.New("invalid 608 roll-up base row")
+		}
+	}
+	w.control(eia608_control_roll_up_2 + uint16(rows) - 2)
+	w.control(eia608_control_carriage_return)
+	if err := w.spans(spans); err != nil {
+		return nil, err
+	}
+	return w.pairs, nil
+}
+
+// PaintOn returns the pairs, with parity, that write the spans directly to the
+// display: RDC and the text. Existing text is only replaced where the spans
+// overwrite it.
+func (e *EIA608Encoder) PaintOn(spans []EIA608Span) ([]uint16, error) {
+	w, err := e.writer()
+	if err != nil {
+		return nil, err
+	}
+	w.control(eia608_control_resume_direct_captioning)
+	if err := w.spans(spans); err != nil {
+		return nil, err
+	}
+	return w.pairs, nil
+}
+
+// EraseDisplay returns the pairs, with parity, that clear the display: EDM.
+func (e *EIA608Encoder) EraseDisplay() ([]uint16, error) {
+	w, err := e.writer()
+	if err != nil {
+		return nil, err
+	}
+	w.control(eia608_control_erase_display_memory)
+	return w.pairs, nil
+}
+
+func (e *EIA608Encoder) writer() (*eia608Writer, error) {
+	if e.Channel < Channel608_CC1 || e.Channel > Channel608_CC4 {
+		return nil, errors.New("invalid 608 channel")
+	}
+	return &eia608Writer{channel: e.Channel}, nil
+}
+
+type eia608Writer struct {
+	channel Channel608
+	pairs   []uint16
+	// a basic character waiting for the second byte of its pair
+	pending byte
+	// current position and style, row 0 if unknown
+	row, col int
+	style    byte
+	under    bool
+	flash    bool
+}
+
+func (w *eia608Writer) flush() {
+	if w.pending != 0 {
+		w.pairs = append(w.pairs, parityWord(uint16(w.pending)<<8))
+		w.pending = 0
+	}
+}
+
+// code adds a code with the channel bit set. Codes other than basic
+// characters are sent twice.
+func (w *eia608Writer) code(cc uint16) {
+	w.flush()
+	if w.channel == Channel608_CC2 || w.channel == Channel608_CC4 {
+		cc |= 0x0800
+	}
+	w.pairs = append(w.pairs, parityWord(cc), parityWord(cc))
+}
+
+func (w *eia608Writer) control(cmd uint16) {
+	if (w.channel == Channel608_CC3 || w.channel == Channel608_CC4) && 0x1400 == cmd&0xFF00 {
+		cmd |= 0x0100 // field 2
+	}
+	w.code(cmd)
+}
+
+func (w *eia608Writer) basic(b byte) {
+	if w.pending == 0 {
+		w.pending = b
+		return
+	}
+	w.pairs = append(w.pairs, parityWord(uint16(w.pending)<<8|uint16(b)))
+	w.pending = 0
+}
+
+func (w *eia608Writer) char(r rune) error {
+	i, ok := charIndex[r]
+	if !ok {
+		return fmt.Errorf("unsupported 608 character %q", r)
+	}
+	switch {
+	case i < 0x60:
+		w.basic(byte(i + 0x20))
+	case i < 0x70:
+		w.code(0x1130 + i - 0x60)
+	case i < 0x90:
+		w.basic(extendedFallback[i-0x70])
+		w.code(0x1220 + i - 0x70)
+	default:
+		w.basic(extendedFallback[i-0x70])
+		w.code(0x1320 + i - 0x90)
+	}
+	w.col++
+	return nil
+}
+
+// spanStyle returns the mid-row/PAC style of a span
+func spanStyle(s *EIA608Span) (byte, error) {
+	switch {
+	case s.Italics:
+		return eia608_style_italics, nil
+	case s.Color == Color608_Black:
+		return eia608_style_black, nil
+	case s.Color < Color608_White || s.Color > Color608_Magenta:
+		return 0, errors.New("unsupported 608 color")
+	}
+	return byte(s.Color), nil
+}
+
+// attributes returns the number of attribute codes, each taking up a column,
+// a span with style needs after from, underline and flash
+func attributes(s *EIA608Span, style, from byte, underline, flash bool) int {
+	n := 0
+	// mid-row codes and black foreground also turn flash off
+	if style != from || s.Underline != underline || flash && !s.Flash {
+		n++
+	}
+	if s.Flash && (n > 0 || !flash) {
+		n++
+	}
+	return n
+}
+
+// preamble moves to row (1-15 from the top) and col, setting style if col is
+// in the first four columns
+func (w *eia608Writer) preamble(row, col int, style byte, underline bool) {
+	idx := 0
+	for i, r := range rowMap {
+		if int(r) == Rows-row {
+			idx = i
+		}
+	}
+	cc := uint16(0x1040) | uint16(idx>>1)<<8 | uint16(idx&1)<<5
+	if col < 4 {
+		cc |= uint16(style) << 1
+	} else {
+		cc |= 0x0010 | uint16(col/4)<<1
+		style = eia608_style_white
+	}
+	if underline {
+		cc |= 0x0001
+	}
+	w.code(cc)
+	w.row, w.col, w.style, w.under, w.flash = row, col/4*4, style, underline, false
+	w.tab(col - w.col)
+}
+
+// tab moves up to three columns right
+func (w *eia608Writer) tab(cols int) {
+	if cols > 0 {
+		w.control(eia608_tab_offset_1 + uint16(cols) - 1)
+		w.col += cols
+	}
+}
+
+// midRow sends a mid-row code, which takes up a column as a space
+func (w *eia608Writer) midRow(style byte, underline bool) {
+	cc := uint16(0x1120) | uint16(style)<<1
+	if underline {
+		cc |= 0x0001
+	}
+	w.code(cc)
+	w.style, w.under, w.flash = style, underline, false
+	w.col++
+}
+
+// black sends a space and the black foreground code that replaces it
+func (w *eia608Writer) black(underline bool) {
+	w.basic(' ')
+	if underline {
+		w.control(eia608_foreground_black_underline)
+	} else {
+		w.control(eia608_foreground_black)
+	}
+	w.style, w.under, w.flash = eia608_style_black, underline, false
+	w.col++
+}
+
+func (w *eia608Writer) spans(spans []EIA608Span) error {
+	for i := range spans {
+		s := &spans[i]
+		runes := []rune(s.Text)
+		if s.Row < 1 || s.Row > Rows || s.Col < 0 || s.Col+len(runes) > Cols {
+			return errors.New("608 span does not fit the display")
+		}
+		style, err := spanStyle(s)
+		if err != nil {
+			return err
+		}
+		// attribute codes take the place of leading spaces, then of the
+		// columns before the span
+		lead := 0
+		for lead < len(runes) && runes[lead] == ' ' {
+			lead++
+		}
+		before := func(n int) int {
+			if n > lead {
+				return n - lead
+			}
+			return 0
+		}
+
+		n := before(attributes(s, style, w.style, w.under, w.flash))
+		if gap := s.Col - w.col; s.Row == w.row && gap >= n && gap-n <= 3 {
+			w.tab(gap - n)
+		} else {
+			// a PAC in the first four columns sets the style itself
+			pac := byte(eia608_style_white)
+			if style != eia608_style_black && s.Col-before(attributes(s, style, style, s.Underline, false)) < 4 {
+				pac = style
+			}
+			col := s.Col - before(attributes(s, style, pac, s.Underline, false))
+			if col < 0 || s.Row == w.row && col < w.col {
+				return errors.New("608 span leaves no column for its attribute codes")
+			}
+			w.preamble(s.Row, col, pac, s.Underline)
+		}
+
+		if style != w.style || s.Underline != w.under || w.flash && !s.Flash {
+			if style == eia608_style_black {
+				w.black(s.Underline)
+			} else {
+				w.midRow(style, s.Underline)
+			}
+		}
+		if s.Flash && !w.flash {
+			w.control(eia608_control_flash_on)
+			w.flash = true
+			w.col++
+		}
+		// the attribute codes took the place of these leading spaces
+		if w.col > s.Col {
+			runes = runes[w.col-s.Col:]
+		}
+		for _, r := range runes {
+			if err := w.char(r); err != nil {
+				return err
+			}
+		}
+	}
+	w.flush()
+	return nil
+}
@@ -0,0 +1,207 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test608_EncodePopOn(t *testing.T) {
+	assert := assert.New(t)
+
+	spans := []EIA608Span{
+		{Row: 1, Col: 0, Text: "RED", Color: Color608_Red, Underline: true, Background: Color608_Black},
+		{Row: 12, Col: 0, Text: "HI", Background: Color608_Black},
+		{Row: 12, Col: 2, Text: " THÉRE", Italics: true, Background: Color608_Black},
+		{Row: 15, Col: 17, Text: "♪ OK", Background: Color608_Black},
+		{Row: 15, Col: 21, Text: " !", Flash: true, Background: Color608_Black},
+		{Row: 15, Col: 23, Text: " OFF", Background: Color608_Black},
+	}
+	encoder := EIA608Encoder{Channel: Channel608_CC1}
+	pairs, err := encoder.PopOn(spans)
+	assert.Nil(err)
+	assert.Equal([]uint16{
+		0x1420, 0x1420, 0x142E, 0x142E, // RCL, ENM
+		0x1149, 0x1149, 0x5245, 0x4400, // row 1, red underline, "RED"
+		0x1340, 0x1340, 0x4849, // row 12 col 0, "HI"
+		0x112E, 0x112E, 0x5448, 0x4500, 0x1221, 0x1221, 0x5245, // italics as " ", "THE", "É", "RE"
+		0x1478, 0x1478, 0x1721, 0x1721, 0x1137, 0x1137, 0x204F, 0x4B00, // row 15 col 16, TO1, "♪", " OK"
+		0x1428, 0x1428, 0x2100, // flash on as " ", "!"
+		0x1120, 0x1120, 0x4F46, 0x4600, // white turns flash off as " ", "OFF"
+		0x142F, 0x142F, // EOC
+	}, stripParity(pairs))
+
+	for ch := Channel608_CC1; ch <= Channel608_CC4; ch++ {
+		encoder := EIA608Encoder{Channel: ch}
+		pairs, err := encoder.PopOn(spans)
+		assert.Nil(err)
+
+		eia608 := EIA608Frame{}
+		for _, cc := range pairs {
+			_, err := eia608.DecodeField(1+int(ch-Channel608_CC1)/2, cc)
+			assert.Nil(err)
+		}
+		assert.Equal(spans, eia608.ChannelSpans(ch))
+	}
+}
+
+func Test608_EncodeRollUp(t *testing.T) {
+	assert := assert.New(t)
+
+	encoder := EIA608Encoder{Channel: Channel608_CC2}
+	pairs, err := encoder.RollUp(2, []EIA608Span{{Row: 15, Col: 4, Text: "HI"}})
+	assert.Nil(err)
+	assert.Equal([]uint16{
+		0x1C25, 0x1C25, 0x1C2D, 0x1C2D, // RU2, CR
+		0x1C72, 0x1C72, 0x4849, // row 15 col 4, "HI"
+	}, stripParity(pairs))
+
+	for ch := Channel608_CC1; ch <= Channel608_CC4; ch++ {
+		encoder := EIA608Encoder{Channel: ch}
+		eia608 := EIA608Frame{}
+		for _, line := range []string{"ONE", "TWO", "THREE", "FOUR"} {
+			pairs, err := encoder.RollUp(3, []EIA608Span{
+				{Row: 14, Text: ">"},
+				{Row: 14, Col: 1, Text: " " + line, Italics: true},
+			})
+			assert.Nil(err)
+			for _, cc := range pairs {
+				_, err := eia608.DecodeField(1+int(ch-Channel608_CC1)/2, cc)
+				assert.Nil(err)
+			}
+		}
+		assert.Equal("> TWO\n> THREE\n> FOUR", eia608.ChannelString(ch))
+		assert.Equal(Mode608_RollUp, eia608.ChannelStateSnapshot(ch).Mode)
+	}
+}
+
+func Test608_EncodePaintOn(t *testing.T) {
+	assert := assert.New(t)
+
+	spans := []EIA608Span{
+		{Row: 2, Col: 9, Text: " PAINT", Color: Color608_Cyan, Background: Color608_Black},
+		{Row: 3, Col: 10, Text: "ON", Background: Color608_Black},
+	}
+	for ch := Channel608_CC1; ch <= Channel608_CC4; ch++ {
+		encoder := EIA608Encoder{Channel: ch}
+		pairs, err := encoder.PaintOn(spans)
+		assert.Nil(err)
+
+		eia608 := EIA608Frame{}
+		for _, cc := range pairs {
+			_, err := eia608.DecodeField(1+int(ch-Channel608_CC1)/2, cc)
+			assert.Nil(err)
+		}
+		assert.Equal(spans, eia608.ChannelSpans(ch))
+		assert.Equal(Mode608_PaintOn, eia608.ChannelStateSnapshot(ch).Mode)
+	}
+}
+
+func Test608_EncodeMidRow(t *testing.T) {
+	assert := assert.New(t)
+
+	// a PAC in the first four columns sets the style of an adjacent span,
+	// other attribute codes go in the free columns before a span
+	encoder := EIA608Encoder{Channel: Channel608_CC1}
+	pairs, err := encoder.PopOn([]EIA608Span{
+		{Row: 15, Text: "AB"},
+		{Row: 15, Col: 2, Text: "C", Italics: true},
+		{Row: 15, Col: 6, Text: "D", Color: Color608_Green},
+		{Row: 15, Col: 9, Text: "E", Color: Color608_Black, Underline: true},
+		{Row: 15, Col: 16, Text: " F", Italics: true, Flash: true},
+	})
+	assert.Nil(err)
+	assert.Equal([]uint16{
+		0x1420, 0x1420, 0x142E, 0x142E, // RCL, ENM
+		0x1460, 0x1460, 0x4142, // row 15 col 0, "AB"
+		0x146E, 0x146E, 0x1722, 0x1722, 0x4300, // row 15 col 0 italics, TO2, "C"
+		0x1722, 0x1722, 0x1122, 0x1122, 0x4400, // TO2, green, "D"
+		0x1721, 0x1721, 0x2000, 0x172F, 0x172F, 0x4500, // TO1, " ", black underline, "E"
+		0x1476, 0x1476, 0x1723, 0x1723, 0x112E, 0x112E, 0x1428, 0x1428, 0x4600, // row 15 col 12, TO3, italics, flash on, "F"
+		0x142F, 0x142F, // EOC
+	}, stripParity(pairs))
+
+	eia608 := EIA608Frame{}
+	for _, cc := range pairs {
+		_, err := eia608.Decode(cc)
+		assert.Nil(err)
+	}
+	assert.Equal([]EIA608Span{
+		{Row: 15, Col: 0, Text: "AB", Background: Color608_Black},
+		{Row: 15, Col: 2, Text: "C", Italics: true, Background: Color608_Black},
+		{Row: 15, Col: 5, Text: " D", Color: Color608_Green, Background: Color608_Black},
+		{Row: 15, Col: 8, Text: " E", Color: Color608_Black, Underline: true, Background: Color608_Black},
+		{Row: 15, Col: 15, Text: " ", Italics: true, Background: Color608_Black},
+		{Row: 15, Col: 16, Text: " F", Italics: true, Flash: true, Background: Color608_Black},
+	}, eia608.Spans())
+
+	// attribute codes never move the text of a span
+	for _, spans := range [][]EIA608Span{
+		{{Row: 15, Text: "AB"}, {Row: 15, Col: 2, Text: "C", Color: Color608_Black}},
+		{{Row: 15, Text: "ABCDEFGHIJKLMNOPQRSTUVWXYZABCDE"}, {Row: 15, Col: 31, Text: "F", Italics: true}},
+		{{Row: 15, Text: "A", Flash: true}},
+		{{Row: 15, Col: 1, Text: "A", Color: Color608_Black, Flash: true}},
+	} {
+		_, err = encoder.PopOn(spans)
+		assert.NotNil(err)
+	}
+}
+
+func Test608_EncodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	encoder := EIA608Encoder{Channel: Channel608_CC4 + 1}
+	_, err := encoder.PopOn(nil)
+	assert.NotNil(err)
+
+	encoder.Channel = Channel608_CC1
+	for _, span := range []EIA608Span{
+		{Row: 0, Text: "A"},
+		{Row: 15, Col: 31, Text: "AB"},
+		{Row: 15, Text: "日本"},
+		{Row: 15, Text: "A", Color: Color608_Black + 1},
+	} {
+		_, err := encoder.PopOn([]EIA608Span{span})
+		assert.NotNil(err)
+	}
+
+	_, err = encoder.RollUp(5, nil)
+	assert.NotNil(err)
+	_, err = encoder.RollUp(3, []EIA608Span{{Row: 2, Text: "A"}})
+	assert.NotNil(err)
+	_, err = encoder.RollUp(2, []EIA608Span{{Row: 14, Text: "A"}, {Row: 15, Text: "B"}})
+	assert.NotNil(err)
+}
+
+func stripParity(pairs []uint16) []uint16 {
+	stripped := make([]uint16, len(pairs))
+	for i, cc := range pairs {
+		stripped[i] = cc & 0x7F7F
+	}
+	return stripped
+}
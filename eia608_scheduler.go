@@ -0,0 +1,251 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FrameRate is a video frame rate that 608 pairs are scheduled for
+type FrameRate int
+
+const (
+	FrameRate_23_976 FrameRate = iota
+	FrameRate_25
+	FrameRate_29_97_DF
+	FrameRate_29_97_NDF
+	FrameRate_50
+	FrameRate_59_94
+)
+
+// frameRates gives the frames per second as num/den, and the number of pairs
+// each frame carries for a field as pairs/frames. 608 runs at one pair per
+// field of 29.97 interlaced video (25 at 25 and 50 fps). ccCount is the number
+// of cc_data triplets each frame carries in CEA-708.
+var frameRates = [...]struct{ num, den, pairs, frames, timebase, ccCount int64 }{
+	FrameRate_23_976:    {24000, 1001, 5, 4, 24, 25},
+	FrameRate_25:        {25, 1, 1, 1, 25, 24},
+	FrameRate_29_97_DF:  {30000, 1001, 1, 1, 30, 20},
+	FrameRate_29_97_NDF: {30000, 1001, 1, 1, 30, 20},
+	FrameRate_50:        {50, 1, 1, 2, 50, 12},
+	FrameRate_59_94:     {60000, 1001, 1, 2, 60, 10},
+}
+
+func (r FrameRate) valid() bool { return r >= FrameRate_23_976 && r <= FrameRate_59_94 }
+
+// Frame returns the number of the frame closest to t
+func (r FrameRate) Frame(t time.Duration) int64 {
+	fr := frameRates[r]
+	return (int64(t)*fr.num + fr.den*int64(time.Second)/2) / (fr.den * int64(time.Second))
+}
+
+// Time returns the time of a frame
+func (r FrameRate) Time(frame int64) time.Duration {
+	fr := frameRates[r]
+	return time.Duration(frame * fr.den * int64(time.Second) / fr.num)
+}
+
+// Timecode returns the SMPTE timecode of a frame, using ';' before the frame
+// count for drop frame.
+func (r FrameRate) Timecode(frame int64) string {
+	fr, sep := frameRates[r], ":"
+	if r == FrameRate_29_97_DF {
+		// frame numbers 0 and 1 are dropped every minute, except every tenth
+		d, m := frame/17982, frame%17982
+		frame += 18*d + 2*((m-2)/1798)
+		sep = ";"
+	}
+	ff := frame % fr.timebase
+	s := frame / fr.timebase
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", s/3600, s/60%60, s%60, sep, ff)
+}
+
+// first slot, counted in pairs from the start, of a frame
+func (r FrameRate) slot(frame int64) int64 {
+	fr := frameRates[r]
+	return (frame*fr.pairs + fr.frames - 1) / fr.frames
+}
+
+// frame a slot is sent in
+func (r FrameRate) frame(slot int64) int64 {
+	fr := frameRates[r]
+	return slot * fr.frames / fr.pairs
+}
+
+// EIA608Late reports a code the scheduler could not send on its frame
+type EIA608Late struct {
+	// Index of the cue
+	Cue int
+	// The late code is the EDM that clears the cue, otherwise the EOC that
+	// displays it
+	Cleared bool
+	// Frame the code was meant for, and the frame it was sent in
+	Frame, Actual int64
+}
+
+// EIA608Schedule is the 608 data for one field of a stream
+type EIA608Schedule struct {
+	// Pairs, with parity, for each frame starting from frame 0. Unused pairs
+	// are 0x8080 padding. A frame may carry no pairs, or more than one.
+	Frames [][]uint16
+	// Codes that did not make it in time
+	Late []EIA608Late
+}
+
+// EIA608Scheduler fits pop-on captions into the 608 bandwidth of a stream
+type EIA608Scheduler struct {
+	Rate    FrameRate
+	Channel Channel608
+}
+
+// Schedule encodes the cues, sorted by start time, as pop-on captions on the
+// scheduler's channel. Cues without spans show their Text, one line per row
+// ending on row 15. The caption is loaded ahead of time so that its EOC
+// is sent on the frame the cue starts. An EDM is sent on the frame the cue
+// ends, unless the next cue replaces it. Cues with no end after their start are
+// left on screen.
+func (s *EIA608Scheduler) Schedule(cues []EIA608Cue) (*EIA608Schedule, error) {
+	if !s.Rate.valid() {
+		return nil, errors.New("invalid frame rate")
+	}
+	encoder := EIA608Encoder{Channel: s.Channel}
+	schedule := EIA608Schedule{}
+	// the pairs of the field, 0 where no pair was placed
+	slots := []uint16{}
+	cursor := int64(0)
+	for i := range cues {
+		cue := &cues[i]
+		if i > 0 && cue.Start < cues[i-1].Start {
+			return nil, errors.New("608 cues are not sorted")
+		}
+		spans := cue.Spans
+		if len(spans) == 0 {
+			spans = textSpans(cue.Text)
+		}
+		pairs, err := encoder.PopOn(spans)
+		if err != nil {
+			return nil, err
+		}
+
+		// load the caption, everything but the EOC
+		load := pairs[:len(pairs)-2]
+		for len(load) > 0 {
+			n := 1
+			if len(load) > 1 && load[0] == load[1] {
+				n = 2 // keep doubled codes together
+			}
+			cursor = reserve(&slots, cursor, load[:n]) + int64(n)
+			load = load[n:]
+		}
+
+		start := s.Rate.Frame(cue.Start)
+		at := s.slotAfter(start, cursor)
+		at = reserve(&slots, at, pairs[len(pairs)-2:])
+		cursor = at + 2
+		if actual := s.Rate.frame(at); actual != start {
+			schedule.Late = append(schedule.Late, EIA608Late{Cue: i, Frame: start, Actual: actual})
+		}
+
+		if cue.End <= cue.Start {
+			continue
+		}
+		end := s.Rate.Frame(cue.End)
+		if i+1 < len(cues) && s.Rate.Frame(cues[i+1].Start) <= end {
+			continue
+		}
+		edm, err := encoder.EraseDisplay()
+		if err != nil {
+			return nil, err
+		}
+		at = reserve(&slots, s.slotAfter(end, cursor), edm)
+		if actual := s.Rate.frame(at); actual != end {
+			schedule.Late = append(schedule.Late, EIA608Late{Cue: i, Cleared: true, Frame: end, Actual: actual})
+		}
+	}
+
+	frames := int64(0)
+	if len(slots) > 0 {
+		frames = s.Rate.frame(int64(len(slots))-1) + 1
+	}
+	for f := int64(0); f < frames; f++ {
+		var pairs []uint16
+		for slot := s.Rate.slot(f); slot < s.Rate.slot(f+1); slot++ {
+			cc := uint16(0x8080)
+			if slot < int64(len(slots)) && slots[slot] != 0 {
+				cc = slots[slot]
+			}
+			pairs = append(pairs, cc)
+		}
+		schedule.Frames = append(schedule.Frames, pairs)
+	}
+	return &schedule, nil
+}
+
+// slotAfter returns the first slot of frame, or cursor if that is later
+func (s *EIA608Scheduler) slotAfter(frame, cursor int64) int64 {
+	if slot := s.Rate.slot(frame); slot > cursor {
+		return slot
+	}
+	return cursor
+}
+
+// reserve puts pairs in the first free run of slots starting at or after from,
+// returning where they went
+func reserve(slots *[]uint16, from int64, pairs []uint16) int64 {
+	for at := from; ; at++ {
+		free := true
+		for i := range pairs {
+			if at+int64(i) < int64(len(*slots)) && (*slots)[at+int64(i)] != 0 {
+				free = false
+			}
+		}
+		if !free {
+			continue
+		}
+		for int64(len(*slots)) < at+int64(len(pairs)) {
+			*slots = append(*slots, 0)
+		}
+		copy((*slots)[at:], pairs)
+		return at
+	}
+}
+
+// textSpans places the lines of text on the bottom rows, starting at column 0,
+// in white on the default black background
+func textSpans(text string) []EIA608Span {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	spans := make([]EIA608Span, len(lines))
+	for i, line := range lines {
+		spans[i] = EIA608Span{Row: 16 - len(lines) + i, Text: line, Background: Color608_Black}
+	}
+	return spans
+}
@@ -0,0 +1,126 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test608_Schedule(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, rate := range []FrameRate{FrameRate_23_976, FrameRate_25, FrameRate_29_97_DF, FrameRate_29_97_NDF, FrameRate_50, FrameRate_59_94} {
+		cues := []EIA608Cue{
+			{Start: rate.Time(60), End: rate.Time(120), Text: "HELLO", Spans: []EIA608Span{{Row: 15, Text: "HELLO", Background: Color608_Black}}},
+			{Start: rate.Time(120), End: rate.Time(200), Text: " WORLD", Spans: []EIA608Span{{Row: 14, Col: 3, Text: " WORLD", Italics: true, Background: Color608_Black}}},
+			{Start: rate.Time(300), End: rate.Time(330), Text: "BYE", Spans: []EIA608Span{{Row: 15, Text: "BYE", Background: Color608_Black}}},
+		}
+		scheduler := EIA608Scheduler{Rate: rate, Channel: Channel608_CC1}
+		schedule, err := scheduler.Schedule(cues)
+		assert.Nil(err)
+		assert.Empty(schedule.Late)
+		again, err := scheduler.Schedule(cues)
+		assert.Nil(err)
+		assert.Equal(schedule, again)
+
+		d := EIA608CueDecoder{}
+		decoded := []EIA608Cue{}
+		for f, pairs := range schedule.Frames {
+			for _, cc := range pairs {
+				out, err := d.Decode(cc, rate.Time(int64(f)))
+				assert.Nil(err)
+				decoded = append(decoded, out...)
+			}
+		}
+		assert.Equal(cues, decoded)
+	}
+}
+
+func Test608_ScheduleText(t *testing.T) {
+	assert := assert.New(t)
+
+	rate := FrameRate_29_97_NDF
+	scheduler := EIA608Scheduler{Rate: rate, Channel: Channel608_CC1}
+	schedule, err := scheduler.Schedule([]EIA608Cue{
+		{Start: rate.Time(60), End: rate.Time(120), Text: "HELLO"},
+		{Start: rate.Time(120), End: rate.Time(200), Text: "TWO\nLINES"},
+	})
+	assert.Nil(err)
+	assert.Empty(schedule.Late)
+
+	d := EIA608CueDecoder{}
+	decoded := []EIA608Cue{}
+	for f, pairs := range schedule.Frames {
+		for _, cc := range pairs {
+			out, err := d.Decode(cc, rate.Time(int64(f)))
+			assert.Nil(err)
+			decoded = append(decoded, out...)
+		}
+	}
+	assert.Len(decoded, 2)
+	assert.Equal("HELLO", decoded[0].Text)
+	assert.Equal([]EIA608Span{{Row: 15, Text: "HELLO", Background: Color608_Black}}, decoded[0].Spans)
+	assert.Equal("TWO\nLINES", decoded[1].Text)
+	assert.Equal([]EIA608Span{{Row: 14, Text: "TWO", Background: Color608_Black}, {Row: 15, Text: "LINES", Background: Color608_Black}}, decoded[1].Spans)
+}
+
+func Test608_ScheduleBandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	scheduler := EIA608Scheduler{Rate: FrameRate_59_94, Channel: Channel608_CC1}
+	schedule, err := scheduler.Schedule([]EIA608Cue{
+		{Start: 0, End: time.Second / 10, Spans: []EIA608Span{{Row: 15, Text: "TOO LATE"}}},
+	})
+	assert.Nil(err)
+	assert.Equal([]uint16{0x9420}, schedule.Frames[0])
+	assert.Empty(schedule.Frames[1])
+	assert.Equal([]EIA608Late{
+		{Cue: 0, Frame: 0, Actual: 20},
+		{Cue: 0, Cleared: true, Frame: 6, Actual: 24},
+	}, schedule.Late)
+
+	_, err = scheduler.Schedule([]EIA608Cue{{Start: time.Second}, {Start: 0}})
+	assert.NotNil(err)
+	scheduler.Rate = FrameRate_59_94 + 1
+	_, err = scheduler.Schedule(nil)
+	assert.NotNil(err)
+}
+
+func TestFrameRate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(int64(1800), FrameRate_29_97_DF.Frame(FrameRate_29_97_DF.Time(1800)))
+	assert.Equal(int64(24), FrameRate_23_976.Frame(1001*time.Millisecond))
+	assert.Equal("00:00:59;29", FrameRate_29_97_DF.Timecode(1799))
+	assert.Equal("00:01:00;02", FrameRate_29_97_DF.Timecode(1800))
+	assert.Equal("00:10:00;00", FrameRate_29_97_DF.Timecode(17982))
+	assert.Equal("01:00:00;00", FrameRate_29_97_DF.Timecode(107892))
+	assert.Equal("00:01:00:00", FrameRate_29_97_NDF.Timecode(1800))
+	assert.Equal("00:00:01:10", FrameRate_50.Timecode(60))
+}
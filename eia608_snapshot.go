@@ -0,0 +1,194 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"errors"
+)
+
+// Memory608 identifies the memory a channel writes to.
+type Memory608 int
+
+const (
+	// No control code seen yet, the channel ignores text
+	Memory608_None Memory608 = iota
+	Memory608_Displayed
+	Memory608_NonDisplayed
+)
+
+// EIA608MemorySnapshot is the state and contents of a single 608 memory.
+type EIA608MemorySnapshot struct {
+	State EIA608State
+	Grid  EIA608Grid
+}
+
+// EIA608ChannelSnapshot is the state of a single caption or text channel.
+type EIA608ChannelSnapshot struct {
+	Active       Memory608
+	Displayed    EIA608MemorySnapshot
+	NonDisplayed EIA608MemorySnapshot
+}
+
+// EIA608Snapshot is the complete state of an EIA608Frame. It only holds exported
+// fields so it can be serialized, e.g. with encoding/json, and restored into a
+// new EIA608Frame to carry on decoding where the old one left off.
+type EIA608Snapshot struct {
+	// Last pair seen on each field, used to skip duplicate control commands
+	CCData [2]uint16
+	// Last channel addressed on each field
+	FieldChannel [2]Channel608
+	Channel      Channel608
+	XDS          bool
+	Captions     [4]EIA608ChannelSnapshot
+	Texts        [4]EIA608ChannelSnapshot
+	TextMode     [4]bool
+}
+
+// Snapshot returns the complete decoder state.
+func (f *EIA608Frame) Snapshot() *EIA608Snapshot {
+	s := &EIA608Snapshot{
+		CCData:       f.ccData,
+		FieldChannel: [2]Channel608{f.fieldChannel(0), f.fieldChannel(1)},
+		Channel:      f.last,
+		XDS:          f.xds,
+		TextMode:     f.textMode,
+	}
+	for i := range f.channels {
+		s.Captions[i] = f.channels[i].snapshot()
+		s.Texts[i] = f.texts[i].snapshot()
+	}
+	return s
+}
+
+// Restore replaces the decoder state with a snapshot taken by Snapshot.
+// Settings such as LenientDuplicates and the stats are kept.
+func (f *EIA608Frame) Restore(s *EIA608Snapshot) error {
+	if s == nil {
+		return errors.New("nil 608 snapshot")
+	}
+	for _, ch := range [...]Channel608{s.FieldChannel[0], s.FieldChannel[1], s.Channel} {
+		if ch < Channel608_CC1 || ch > Channel608_CC4 {
+			return errors.New("invalid 608 snapshot channel")
+		}
+	}
+
+	r := EIA608Frame{
+		LenientDuplicates: f.LenientDuplicates,
+		Parity:            f.Parity,
+		Trace:             f.Trace,
+		stats:             f.stats,
+
+		ccData: s.CCData,
+		// only the data channel bit is kept, the field picks CC1/CC2 or CC3/CC4
+		channelBit: [2]Channel608{s.FieldChannel[0] & 1, s.FieldChannel[1] & 1},
+		last:       s.Channel,
+		xds:        s.XDS,
+		textMode:   s.TextMode,
+	}
+	for i := range r.channels {
+		if err := r.channels[i].restore(&s.Captions[i]); err != nil {
+			return err
+		}
+		if err := r.texts[i].restore(&s.Texts[i]); err != nil {
+			return err
+		}
+		r.texts[i].text = true
+	}
+	*f = r
+	// active points into the channels, so fix it up after the copy
+	for i := range f.channels {
+		f.channels[i].active = f.channels[i].memory(s.Captions[i].Active)
+		f.texts[i].active = f.texts[i].memory(s.Texts[i].Active)
+	}
+	return nil
+}
+
+func (c *eia608Channel) memory(m Memory608) *frameBuffer {
+	switch m {
+	case Memory608_Displayed:
+		return &c.front
+	case Memory608_NonDisplayed:
+		return &c.back
+	}
+	return nil
+}
+
+func (c *eia608Channel) snapshot() EIA608ChannelSnapshot {
+	s := EIA608ChannelSnapshot{
+		Displayed:    c.front.snapshot(),
+		NonDisplayed: c.back.snapshot(),
+	}
+	switch c.active {
+	case &c.front:
+		s.Active = Memory608_Displayed
+	case &c.back:
+		s.Active = Memory608_NonDisplayed
+	}
+	return s
+}
+
+func (c *eia608Channel) restore(s *EIA608ChannelSnapshot) error {
+	if s.Active < Memory608_None || s.Active > Memory608_NonDisplayed {
+		return errors.New("invalid 608 snapshot memory")
+	}
+	if err := c.front.restore(&s.Displayed); err != nil {
+		return err
+	}
+	return c.back.restore(&s.NonDisplayed)
+}
+
+func (b *frameBuffer) snapshot() EIA608MemorySnapshot {
+	return EIA608MemorySnapshot{State: b.snapshotState(), Grid: *b.grid()}
+}
+
+func (b *frameBuffer) restore(s *EIA608MemorySnapshot) error {
+	st := &s.State
+	if st.Row < 0 || st.Row > Rows || st.CursorRow < 0 || st.CursorRow > Rows ||
+		st.CursorCol < 0 || st.CursorCol > Cols {
+		return errors.New("invalid 608 snapshot cursor")
+	}
+	b.state = EIA608State{Rollup: st.Rollup, Row: Rows - st.Row, Col: st.Col}
+	b.row, b.col = uint(Rows-st.CursorRow), uint(st.CursorCol)
+	b.pen = st.Pen.char(0)
+	for r := range s.Grid {
+		for c, cell := range s.Grid[r] {
+			char := frameBufferChar{}
+			if cell.Kind != CellKind608_Empty {
+				char = EIA608Pen{
+					Color:      cell.Color,
+					Background: cell.Background,
+					Opacity:    cell.Opacity,
+					Italics:    cell.Italics,
+					Underline:  cell.Underline,
+					Flash:      cell.Flash,
+				}.char(cell.Char)
+			}
+			// the grid has the top row first
+			b.data[Rows-1-r][c] = char
+		}
+	}
+	return nil
+}
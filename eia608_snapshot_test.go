@@ -0,0 +1,93 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"encoding/json"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test608_SnapshotRestore(t *testing.T) {
+	assert := assert.New(t)
+
+	decode := func(eia608 *EIA608Frame, cc ...uint16) {
+		for _, c := range cc {
+			_, err := eia608.Decode(parityWord(c))
+			assert.Nil(err)
+		}
+	}
+
+	eia608 := EIA608Frame{}
+	decode(&eia608,
+		0x1420, 0x1420, 0x1370, 0x1370, 0x4849, // CC1 RCL, row 13, "HI"
+		0x142F, 0x142F, // EOC
+		0x1420, 0x1420, 0x1449, 0x1449, 0x4E45, // RCL, row 14 red underline, "NE"
+		0x1C25, 0x1C25, 0x4F4E, 0x4500, // CC2 RU2, "ONE"
+		0x1C2D, 0x1C2D, 0x182B, 0x182B, 0x5457, // CR, yellow background (a space), "TW"
+		0x1C2A, 0x1C2A, 0x5431, // T2 TR, "T1"
+		0x1420, // first half of a CC1 RCL pair
+	)
+
+	// round trip through json like a packager handing off to another node
+	data, err := json.Marshal(eia608.Snapshot())
+	assert.Nil(err)
+	snapshot := EIA608Snapshot{}
+	assert.Nil(json.Unmarshal(data, &snapshot))
+
+	restored := EIA608Frame{}
+	assert.Nil(restored.Restore(&snapshot))
+	assert.Equal(eia608.Snapshot(), restored.Snapshot())
+
+	// both decoders carry on identically, the duplicate RCL is skipped by both
+	rest := []uint16{
+		0x1420, 0x5854, 0x142F, 0x142F, // CC1 "XT", EOC
+		0x1C25, 0x1C25, 0x4F00, // CC2 "O"
+		0x1C2B, 0x1C2B, 0x3200, // T2 "2"
+	}
+	decode(&eia608, rest...)
+	decode(&restored, rest...)
+	assert.Equal("NEXT", restored.String())
+	assert.Equal(eia608.Spans(), restored.Spans())
+	assert.Equal("ONE\n TWO", restored.ChannelString(Channel608_CC2))
+	assert.Equal(eia608.ChannelSpans(Channel608_CC2), restored.ChannelSpans(Channel608_CC2))
+	assert.Equal("T12", restored.TextString(Channel608_CC2))
+	assert.Equal(eia608.Snapshot(), restored.Snapshot())
+
+	// tab offsets stop at the last column, so the snapshot can be restored
+	eia608 = EIA608Frame{}
+	decode(&eia608, 0x1420, 0x1420, 0x147E, 0x147E, 0x4142, 0x4344, 0x1723, 0x1723) // RCL, row 15 col 28, "ABCD", TO3
+	tabbed := eia608.Snapshot()
+	assert.Equal(Cols-1, tabbed.Captions[Channel608_CC1].NonDisplayed.State.CursorCol)
+	restored = EIA608Frame{}
+	assert.Nil(restored.Restore(tabbed))
+	assert.Equal(tabbed, restored.Snapshot())
+
+	snapshot.Channel = Channel608(7)
+	assert.NotNil(restored.Restore(&snapshot))
+	assert.NotNil(restored.Restore(nil))
+}
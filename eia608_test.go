@@ -90,26 +90,303 @@ func Test608_StateSnapshot(t *testing.T) {
 	assert.Equal(EIA608State{Mode: Mode608_Unknown}, *state)
 
 	// write to back buffer. state should still pull from (empty) front
-	eia608.active = &eia608.back
-	eia608.writeChar(72)
-	eia608.writeChar(69)
-	eia608.writeChar(76)
-	eia608.writeChar(76)
-	eia608.writeChar(79)
+	cc1 := &eia608.channels[Channel608_CC1]
+	cc1.active = &cc1.back
+	cc1.writeChar(72)
+	cc1.writeChar(69)
+	cc1.writeChar(76)
+	cc1.writeChar(76)
+	cc1.writeChar(79)
 	state = eia608.StateSnapshot()
 	assert.NotNil(state)
-	assert.Equal(EIA608State{Mode: Mode608_PopOn, Row: 15}, *state)
+	pen := EIA608Pen{Background: Color608_Black}
+	assert.Equal(EIA608State{Mode: Mode608_PopOn, Row: 15, CursorRow: 15, Pen: pen}, *state)
 
 	// swap front and back buffers, check contents
-	eia608.front, eia608.back = eia608.back, eia608.front
-	eia608.active = &eia608.back
+	cc1.front, cc1.back = cc1.back, cc1.front
+	cc1.active = &cc1.back
 	state = eia608.StateSnapshot()
 	assert.NotNil(state)
-	assert.Equal(EIA608State{Mode: Mode608_PopOn, Row: 15, Content: "hello"}, *state)
+	assert.Equal(EIA608State{Mode: Mode608_PopOn, Row: 15, CursorRow: 15, CursorCol: 5, Pen: pen, Content: "hello"}, *state)
 
 	// force paint-on
-	eia608.front.state.Rollup = 1
+	cc1.front.state.Rollup = 1
 	state = eia608.StateSnapshot()
 	assert.NotNil(state)
-	assert.Equal(EIA608State{Mode: Mode608_PaintOn, Row: 15, Rollup: 1, Content: "hello"}, *state)
+	assert.Equal(EIA608State{Mode: Mode608_PaintOn, Row: 15, Rollup: 1, CursorRow: 15, CursorCol: 5, Pen: pen, Content: "hello"}, *state)
+}
+
+func Test608_Channels(t *testing.T) {
+	assert := assert.New(t)
+
+	decode := func(eia608 *EIA608Frame, field int, cc ...uint16) {
+		for _, c := range cc {
+			_, err := eia608.DecodeField(field, parityWord(c))
+			assert.Nil(err)
+		}
+	}
+
+	// CC1 and CC2 interleaved on field 1, text follows the last control code
+	eia608 := EIA608Frame{}
+	decode(&eia608, 1,
+		0x1420, 0x1420, 0x1440, 0x1440, 0x4849, // RCL, PAC, "HI"
+		0x1C20, 0x1C20, 0x1C40, 0x1C40, 0x484F, 0x4C41, // CC2 RCL, PAC, "HOLA"
+		0x142F, 0x142F, // CC1 EOC
+	)
+	assert.Equal(Channel608_CC1, eia608.Channel())
+	assert.Equal("HI", eia608.String())
+	assert.Equal("", eia608.ChannelString(Channel608_CC2))
+
+	decode(&eia608, 1, 0x1C2F, 0x1C2F) // CC2 EOC
+	assert.Equal(Channel608_CC2, eia608.Channel())
+	assert.Equal("HI", eia608.ChannelString(Channel608_CC1))
+	assert.Equal("HOLA", eia608.ChannelString(Channel608_CC2))
+	assert.Equal(Mode608_PopOn, eia608.ChannelStateSnapshot(Channel608_CC2).Mode)
+
+	// field 2 carries CC3 and CC4
+	decode(&eia608, 2, 0x1425, 0x1425, 0x4F4B, 0x1D25, 0x1D25, 0x5945, 0x5300)
+	assert.Equal("OK", eia608.ChannelString(Channel608_CC3))
+	assert.Equal("YES", eia608.ChannelString(Channel608_CC4))
+	assert.Equal("HI", eia608.String())
+
+	_, err := eia608.DecodeField(3, 0x8080)
+	assert.NotNil(err)
+
+	// field 2 text before any field 2 control code belongs to CC3, not CC1
+	eia608 = EIA608Frame{}
+	decode(&eia608, 1, 0x1429, 0x1429, 0x1440, 0x1440, 0x4849) // RDC, PAC, "HI"
+	assert.Equal("HI", eia608.String())
+	decode(&eia608, 2, 0x5858)
+	assert.Equal(Channel608_CC3, eia608.Channel())
+	assert.Equal("HI", eia608.ChannelString(Channel608_CC1))
+	assert.Equal("", eia608.ChannelString(Channel608_CC3))
+	assert.Equal([2]Channel608{Channel608_CC1, Channel608_CC3}, eia608.Snapshot().FieldChannel)
+}
+
+func Test608_TextMode(t *testing.T) {
+	assert := assert.New(t)
+
+	eia608 := EIA608Frame{}
+	for _, c := range []uint16{
+		0x1420, 0x1420, 0x4849, // CC1 RCL, "HI"
+		0x1C2A, 0x1C2A, 0x5354, 0x4154, 0x494F, 0x4E00, // T2 TR, "STATION"
+		0x1C2D, 0x1C2D, 0x1C40, 0x1C40, 0x494E, 0x464F, // T2 CR, PAC is ignored, "INFO"
+		0x142F, 0x142F, // CC1 EOC
+		0x1C20, 0x1C20, 0x4F4B, 0x1C2F, 0x1C2F, // CC2 RCL, "OK", EOC
+	} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+	assert.Equal("HI", eia608.String())
+	assert.Equal("OK", eia608.ChannelString(Channel608_CC2))
+	assert.Equal("STATION\nINFO", eia608.TextString(Channel608_CC2))
+	assert.Equal("", eia608.TextString(Channel608_CC1))
+
+	// resume text display keeps the buffer, text restart clears it
+	for _, c := range []uint16{0x1C2B, 0x1C2B, 0x1C2D, 0x1C2D, 0x5858} {
+		eia608.Decode(parityWord(c))
+	}
+	assert.Equal("STATION\nINFO\nXX", eia608.TextString(Channel608_CC2))
+	assert.Equal("OK", eia608.ChannelString(Channel608_CC2))
+	for _, c := range []uint16{0x1C2A, 0x1C2A, 0x5959} {
+		eia608.Decode(parityWord(c))
+	}
+	assert.Equal("YY", eia608.TextString(Channel608_CC2))
+}
+
+func Test608_Spans(t *testing.T) {
+	assert := assert.New(t)
+
+	eia608 := EIA608Frame{}
+	for _, c := range []uint16{
+		0x1420, 0x1420, 0x1350, 0x1350, 0x4849, // RCL, row 12 col 0, "HI"
+		0x112E, 0x112E, 0x5448, 0x4552, 0x4500, // italics mid-row as a space, "THERE"
+		0x1478, 0x1478, 0x4F4B, // row 15 col 16, "OK"
+		0x1428, 0x1428, 0x2100, // flash on as a space, "!"
+		0x1149, 0x1149, 0x5245, 0x4400, // row 1, red underline, "RED"
+		0x142F, 0x142F, // EOC
+	} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+	assert.Equal([]EIA608Span{
+		{Row: 1, Col: 0, Text: "RED", Color: Color608_Red, Underline: true, Background: Color608_Black},
+		{Row: 12, Col: 0, Text: "HI", Background: Color608_Black},
+		{Row: 12, Col: 2, Text: " THERE", Italics: true, Background: Color608_Black},
+		{Row: 15, Col: 16, Text: "OK", Background: Color608_Black},
+		{Row: 15, Col: 18, Text: " !", Flash: true, Background: Color608_Black},
+	}, eia608.Spans())
+	assert.Empty(eia608.ChannelSpans(Channel608_CC2))
+}
+
+func Test608_Background(t *testing.T) {
+	assert := assert.New(t)
+
+	eia608 := EIA608Frame{}
+	for _, c := range []uint16{
+		0x1420, 0x1420, 0x1470, 0x1470, 0x4100, // RCL, row 15, "A"
+		0x2000, 0x102B, 0x102B, 0x4200, // space, yellow semi-transparent background, "B"
+		0x1139, 0x1139, 0x4300, // transparent space, "C"
+		0x2000, 0x172E, 0x172E, 0x4400, // space, black foreground, "D"
+		0x2000, 0x172D, 0x172D, 0x4500, // space, transparent background, "E"
+		0x142F, 0x142F, // EOC
+	} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+	yellow := EIA608Span{Row: 15, Background: Color608_Yellow, Opacity: Opacity608_SemiTransparent}
+	transparent := EIA608Span{Row: 15, Background: Color608_Black, Opacity: Opacity608_Transparent}
+	span := func(s EIA608Span, col int, text string, color Color608) EIA608Span {
+		s.Col, s.Text, s.Color = col, text, color
+		return s
+	}
+	assert.Equal([]EIA608Span{
+		{Row: 15, Col: 0, Text: "A", Background: Color608_Black},
+		span(yellow, 1, " B", Color608_White),
+		span(transparent, 3, " ", Color608_White),
+		span(yellow, 4, "C", Color608_White),
+		span(yellow, 5, " D", Color608_Black),
+		span(transparent, 7, " E", Color608_Black),
+	}, eia608.Spans())
+	assert.Equal("A B C D E", eia608.String())
+}
+
+func Test608_Grid(t *testing.T) {
+	assert := assert.New(t)
+
+	eia608 := EIA608Frame{}
+	for _, c := range []uint16{
+		0x1420, 0x1420, 0x1370, 0x1370, 0x4120, 0x4200, // RCL, row 13, "A B"
+		0x1721, 0x1721, 0x1139, 0x1139, // tab offset 1, transparent space
+		0x142F, 0x142F, // EOC
+		0x1450, 0x1450, 0x5800, // row 14, "X" into non-displayed memory
+	} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+
+	g := eia608.DisplayedGrid(Channel608_CC1)
+	assert.NotNil(g)
+	cell := EIA608Cell{Kind: CellKind608_Char, Char: 'A', Background: Color608_Black}
+	assert.Equal(cell, g[12][0])
+	cell.Kind, cell.Char = CellKind608_Space, ' '
+	assert.Equal(cell, g[12][1])
+	assert.Equal(CellKind608_Char, g[12][2].Kind)
+	assert.Equal(EIA608Cell{}, g[12][3])
+	cell.Opacity = Opacity608_Transparent
+	assert.Equal(cell, g[12][4])
+	assert.Equal(EIA608Cell{}, g[13][0])
+
+	g = eia608.NonDisplayedGrid(Channel608_CC1)
+	assert.Equal('X', g[13][0].Char)
+	assert.Equal(EIA608Cell{}, g[12][0])
+	assert.Nil(eia608.DisplayedGrid(Channel608(4)))
+}
+
+func Test608_Events(t *testing.T) {
+	assert := assert.New(t)
+
+	eia608 := EIA608Frame{}
+	decode := func(cc ...uint16) []EIA608Event {
+		events := []EIA608Event{}
+		for _, c := range cc {
+			e, err := eia608.DecodeEvents(parityWord(c))
+			assert.Nil(err)
+			events = append(events, e...)
+		}
+		return events
+	}
+	event := func(t Event608) EIA608Event { return EIA608Event{Type: t} }
+
+	assert.Equal([]EIA608Event{{Type: Event608_ModeChanged, Mode: Mode608_PopOn}},
+		decode(0x1420, 0x1420, 0x1470, 0x1470, 0x4849)) // RCL, PAC, "HI" into non-displayed memory
+	assert.Equal([]EIA608Event{event(Event608_CaptionCommitted)}, decode(0x142F, 0x142F))
+	assert.Equal([]EIA608Event{event(Event608_DisplayCleared)}, decode(0x142C, 0x142C))
+	assert.Equal([]EIA608Event{
+		{Type: Event608_ModeChanged, Mode: Mode608_RollUp},
+		event(Event608_DisplayUpdated),
+		event(Event608_RollUp),
+	}, decode(0x1425, 0x1425, 0x4100, 0x142D, 0x142D)) // RU2, "A", CR
+	assert.Equal([]EIA608Event{
+		event(Event608_NonDisplayedCleared),
+		{Type: Event608_Alarm, Alarm: true},
+	}, decode(0x142E, 0x142E, 0x1423, 0x1423)) // ENM, alarm on
+	assert.Equal([]EIA608Event{
+		{Type: Event608_ModeChanged, Mode: Mode608_Text},
+		event(Event608_TextUpdated), // text restart clears the buffer
+		event(Event608_TextUpdated),
+		{Type: Event608_ModeChanged, Channel: Channel608_CC2, Mode: Mode608_PaintOn},
+		{Type: Event608_DisplayUpdated, Channel: Channel608_CC2},
+	}, decode(0x142A, 0x142A, 0x5800, 0x1C29, 0x1C29, 0x5900)) // TR, "X", CC2 RDC, "Y"
+	assert.Equal(Mode608_RollUp, eia608.StateSnapshot().Mode)
+}
+
+func Test608_Duplicates(t *testing.T) {
+	assert := assert.New(t)
+
+	decode := func(eia608 *EIA608Frame, cc ...uint16) {
+		for _, c := range cc {
+			_, err := eia608.Decode(parityWord(c))
+			assert.Nil(err)
+		}
+	}
+
+	for _, test := range []struct {
+		lenient bool
+		text    []string
+	}{
+		{false, []string{"ABCDE", "ABC", "A", "♪É"}},
+		{true, []string{"ABCDE", "ABCD", "ABCD", "♪ÉCD"}},
+	} {
+		eia608 := EIA608Frame{LenientDuplicates: test.lenient}
+		decode(&eia608, 0x1429, 0x1429, 0x1470, 0x1470, 0x4142, 0x4344, 0x4500) // RDC, PAC, "ABCDE"
+		assert.Equal(test.text[0], eia608.String())
+
+		// the backspace is meant twice
+		decode(&eia608, 0x1421, 0x1421, 0x1421, 0x1421)
+		assert.Equal(test.text[1], eia608.String())
+
+		// padding between copies
+		decode(&eia608, 0x1421, 0x0000, 0x1421)
+		assert.Equal(test.text[2], eia608.String())
+
+		// special and extended characters are doubled
+		decode(&eia608, 0x1470, 0x1470, 0x1137, 0x1137, 0x4500, 0x1221, 0x1221)
+		assert.Equal(test.text[3], eia608.String())
+	}
+}
+
+func Test608_Parity(t *testing.T) {
+	assert := assert.New(t)
+
+	hi := parityWord(0x4849) ^ 0x0080 // "HI" with a bad "I"
+	rcl := parityWord(0x1420) ^ 0x8000
+	for _, test := range []struct {
+		parity Parity608
+		text   string
+	}{
+		{Parity608_Drop, "OK"},
+		{Parity608_Replace, "H█OK"},
+		{Parity608_Error, "OK"},
+	} {
+		eia608 := EIA608Frame{Parity: test.parity}
+		for _, c := range []uint16{rcl, parityWord(0x1420), hi, parityWord(0x4F4B), parityWord(0x142F), parityWord(0x142F)} {
+			_, err := eia608.Decode(c)
+			if test.parity == Parity608_Error && (c == hi || c == rcl) {
+				assert.Equal(EIA608ParityError{Field: 1, CCData: c}, err)
+			} else {
+				assert.Nil(err)
+			}
+		}
+		assert.Equal(test.text, eia608.String())
+		assert.Equal(2, eia608.Stats().ParityErrors)
+	}
+
+	eia608 := EIA608Frame{}
+	for _, c := range []uint16{0x1420, 0x1724, 0x1724, 0x1000, 0x0F00} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+	assert.Equal(2, eia608.Stats().UnknownCodes)
+	assert.Equal(1, eia608.Stats().UnsupportedCommands)
 }
@@ -0,0 +1,153 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// abbreviations of the control codes, from CEA-608
+var commandNames = map[uint16]string{
+	eia608_control_resume_caption_loading:     "RCL",
+	eia608_control_backspace:                  "BS",
+	eia608_control_alarm_off:                  "AOF",
+	eia608_control_alarm_on:                   "AON",
+	eia608_control_delete_to_end_of_row:       "DER",
+	eia608_control_roll_up_2:                  "RU2",
+	eia608_control_roll_up_3:                  "RU3",
+	eia608_control_roll_up_4:                  "RU4",
+	eia608_control_flash_on:                   "FON",
+	eia608_control_resume_direct_captioning:   "RDC",
+	eia608_control_text_restart:               "TR",
+	eia608_control_text_resume_text_display:   "RTD",
+	eia608_control_erase_display_memory:       "EDM",
+	eia608_control_carriage_return:            "CR",
+	eia608_control_erase_non_displayed_memory: "ENM",
+	eia608_control_end_of_caption:             "EOC",
+	eia608_tab_offset_1:                       "TO1",
+	eia608_tab_offset_2:                       "TO2",
+	eia608_tab_offset_3:                       "TO3",
+	eia608_background_transparent:             "BT",
+	eia608_foreground_black:                   "FA",
+	eia608_foreground_black_underline:         "FAU",
+}
+
+var colorNames = []string{"white", "green", "blue", "cyan", "red", "yellow", "magenta", "black"}
+
+func (c Color608) String() string {
+	if c < Color608_White || c > Color608_Black {
+		return fmt.Sprintf("Color608(%d)", int(c))
+	}
+	return colorNames[c]
+}
+
+// commandName returns the abbreviation of a control code, PAC, mid-row or
+// background code, or "" for characters and unknown codes.
+func commandName(ccData uint16) string {
+	switch {
+	case isControl(ccData):
+		if name, ok := commandNames[controlCommand(ccData)]; ok {
+			return name
+		}
+		return fmt.Sprintf("0x%04X", controlCommand(ccData))
+	case isPreamble(ccData):
+		return "PAC"
+	case isMidRowChange(ccData):
+		return "MRC"
+	case isBackground(ccData):
+		return "BG"
+	}
+	return ""
+}
+
+func describePen(p EIA608Pen) string {
+	s := []string{p.Color.String()}
+	if p.Italics {
+		s[0] = "italics"
+	}
+	if p.Underline {
+		s = append(s, "underline")
+	}
+	return strings.Join(s, " ")
+}
+
+// describe608 returns a human readable description of a pair
+func describe608(ccData uint16) string {
+	switch {
+	case isControl(ccData):
+		return commandName(ccData)
+	case isPreamble(ccData):
+		row := Rows - int(rowMap[((0x0700&ccData)>>7)|((0x0020&ccData)>>5)])
+		c := frameBufferChar{underline: 0x0001&ccData == 1}
+		if 0x0010&ccData == 0 {
+			c.style = byte((0x000E & ccData) >> 1)
+			return fmt.Sprintf("PAC row %d %s", row, describePen(c.attributes()))
+		}
+		return fmt.Sprintf("PAC row %d col %d %s", row, 4*((0x000E&ccData)>>1), describePen(c.attributes()))
+	case isMidRowChange(ccData):
+		c := frameBufferChar{underline: 0x0001&ccData == 1, style: byte((0x000E & ccData) >> 1)}
+		return "MRC " + describePen(c.attributes())
+	case isBackground(ccData):
+		p := frameBufferChar{background: byte(0x000F&ccData) + 1}.attributes()
+		if p.Opacity == Opacity608_SemiTransparent {
+			return fmt.Sprintf("BG %s semi-transparent", p.Background)
+		}
+		return fmt.Sprintf("BG %s", p.Background)
+	case isSpecialNA(ccData):
+		return fmt.Sprintf("special %q", string(charMap[(ccData&0xF7FF)-0x1130+0x60]))
+	case isWesternEu(ccData):
+		if 0x1220 <= ccData&0xF7FF && 0x1240 > ccData&0xF7FF {
+			return fmt.Sprintf("extended %q", string(charMap[(ccData&0xF7FF)-0x1220+0x70]))
+		}
+		return fmt.Sprintf("extended %q", string(charMap[(ccData&0xF7FF)-0x1320+0x90]))
+	case isBasicNA(ccData):
+		s := string(charMap[(ccData>>8)-0x20])
+		if 0x0020 <= ccData&0x00FF && 0x0080 > ccData&0x00FF {
+			s += string(charMap[(ccData&0x00FF)-0x20])
+		}
+		return fmt.Sprintf("text %q", s)
+	}
+	return fmt.Sprintf("unknown 0x%04X", ccData)
+}
+
+func (f *EIA608Frame) tracef(format string, a ...interface{}) {
+	if f.Trace != nil {
+		f.Trace(fmt.Sprintf(format, a...))
+	}
+}
+
+// trace describes a pair decoded on a caption or text service
+func (f *EIA608Frame) trace(ch Channel608, text bool, ccData uint16) {
+	if f.Trace == nil {
+		return
+	}
+	if text {
+		f.tracef("T%d %s", ch+1, describe608(ccData))
+	} else {
+		f.tracef("CC%d %s", ch+1, describe608(ccData))
+	}
+}
@@ -0,0 +1,104 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func Test608_Trace(t *testing.T) {
+	assert := assert.New(t)
+
+	trace := []string{}
+	eia608 := EIA608Frame{Trace: func(s string) { trace = append(trace, s) }}
+	for _, c := range []uint16{
+		0x1420, 0x1420, 0x0000, 0x1470, 0x1470, 0x4849, // RCL, padding, PAC, "HI"
+		0x112E, 0x112E, 0x1137, 0x1137, 0x1221, 0x1221, // italics, "♪", "É"
+		0x1C20, 0x1C20, 0x1C54, 0x1C54, 0x4100, 0x182B, 0x182B, // CC2 RCL, PAC, "A", BG
+		0x142A, 0x142A, 0x4F4B, // TR, "OK"
+		0x142F, 0x142F, // EOC
+	} {
+		_, err := eia608.Decode(parityWord(c))
+		assert.Nil(err)
+	}
+	_, err := eia608.Decode(0x4849)
+	assert.Nil(err)
+
+	assert.Equal([]string{
+		`CC1 RCL`,
+		`CC1 PAC row 15 col 0 white`,
+		`CC1 text "HI"`,
+		`CC1 MRC italics`,
+		`CC1 special "♪"`,
+		`CC1 extended "É"`,
+		`CC2 RCL`,
+		`CC2 PAC row 14 col 8 white`,
+		`CC2 text "A"`,
+		`CC2 BG yellow semi-transparent`,
+		`T1 TR`,
+		`T1 text "OK"`,
+		`CC1 EOC`,
+		`field 1 parity error 0x4849`,
+	}, trace)
+
+	stats := eia608.Stats()
+	assert.Equal(25, stats.Pairs)
+	assert.Equal(1, stats.Padding)
+	assert.Equal(10, stats.Duplicates)
+	assert.Equal(map[string]int{"RCL": 2, "PAC": 2, "MRC": 1, "BG": 1, "TR": 1, "EOC": 1}, stats.Commands)
+	assert.Equal(2, stats.ChannelSwitches)
+	assert.Equal(1, stats.Captions)
+	assert.Equal(1, stats.ParityErrors)
+
+	// stats are a copy
+	stats.Commands["EOC"] = 10
+	assert.Equal(1, eia608.Stats().Commands["EOC"])
+}
+
+func Test608_ChannelSwitches(t *testing.T) {
+	assert := assert.New(t)
+
+	// CC1 on field 1 interleaved with CC3 on field 2 does not switch channels
+	eia608 := EIA608Frame{}
+	field1 := []uint16{0x1420, 0x1420, 0x1470, 0x1470, 0x4849, 0x142F, 0x142F}
+	field2 := []uint16{0x1520, 0x1520, 0x1570, 0x1570, 0x4F4B, 0x152F, 0x152F}
+	for i := range field1 {
+		_, err := eia608.DecodeField(1, parityWord(field1[i]))
+		assert.Nil(err)
+		_, err = eia608.DecodeField(2, parityWord(field2[i]))
+		assert.Nil(err)
+	}
+	assert.Equal("HI", eia608.ChannelString(Channel608_CC1))
+	assert.Equal("OK", eia608.ChannelString(Channel608_CC3))
+	assert.Equal(0, eia608.Stats().ChannelSwitches)
+
+	// CC4 on field 2 is a switch
+	_, err := eia608.DecodeField(2, parityWord(0x1D20))
+	assert.Nil(err)
+	assert.Equal(1, eia608.Stats().ChannelSwitches)
+}
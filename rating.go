@@ -0,0 +1,227 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Content advisory (V-chip) ratings carried in XDS.
+
+References: https://shop.cta.tech/products/line-21-data-services
+            https://www.govinfo.gov/content/pkg/CFR-2007-title47-vol1/pdf/CFR-2007-title47-vol1-sec15-120.pdf
+*/
+
+type RatingSystem int
+
+const (
+	RatingSystem_None RatingSystem = iota
+	RatingSystem_MPA
+	RatingSystem_USTV
+	RatingSystem_CanadianEnglish
+	RatingSystem_CanadianFrench
+	// Reserved for non North American systems
+	RatingSystem_Reserved
+)
+
+type MPARating int
+
+const (
+	MPARating_NA MPARating = iota
+	MPARating_G
+	MPARating_PG
+	MPARating_PG13
+	MPARating_R
+	MPARating_NC17
+	MPARating_X
+	MPARating_NotRated
+)
+
+type TVRating int
+
+const (
+	TVRating_None TVRating = iota
+	TVRating_Y
+	TVRating_Y7
+	TVRating_G
+	TVRating_PG
+	TVRating_14
+	TVRating_MA
+)
+
+type CanadianEnglishRating int
+
+const (
+	CanadianEnglishRating_Exempt CanadianEnglishRating = iota
+	CanadianEnglishRating_C
+	CanadianEnglishRating_C8
+	CanadianEnglishRating_G
+	CanadianEnglishRating_PG
+	CanadianEnglishRating_14
+	CanadianEnglishRating_18
+	CanadianEnglishRating_Reserved
+)
+
+type CanadianFrenchRating int
+
+const (
+	CanadianFrenchRating_Exempt CanadianFrenchRating = iota
+	CanadianFrenchRating_G
+	CanadianFrenchRating_8
+	CanadianFrenchRating_13
+	CanadianFrenchRating_16
+	CanadianFrenchRating_18
+	CanadianFrenchRating_Reserved
+	// 7 is not a valid Canadian French rating
+	CanadianFrenchRating_Invalid
+)
+
+// Rating is a decoded content advisory. Only the field matching System is set.
+type Rating struct {
+	System          RatingSystem
+	MPA             MPARating
+	TV              TVRating
+	CanadianEnglish CanadianEnglishRating
+	CanadianFrench  CanadianFrenchRating
+
+	// U.S. TV Parental Guidelines content flags
+	Dialogue        bool // D, TV-PG and TV-14 only
+	Language        bool // L, TV-PG, TV-14 and TV-MA only
+	Sex             bool // S, TV-PG, TV-14 and TV-MA only
+	Violence        bool // V, TV-PG, TV-14 and TV-MA only
+	FantasyViolence bool // FV, TV-Y7 only
+}
+
+// RatingChange is reported when the content advisory changes. Previous has
+// System set to RatingSystem_None for the first rating seen.
+type RatingChange struct {
+	Previous Rating
+	Current  Rating
+}
+
+var (
+	mpaRatingNames             = []string{"N/A", "G", "PG", "PG-13", "R", "NC-17", "X", "Not Rated"}
+	tvRatingNames              = []string{"None", "TV-Y", "TV-Y7", "TV-G", "TV-PG", "TV-14", "TV-MA", "None"}
+	canadianEnglishRatingNames = []string{"Exempt", "C", "C8+", "G", "PG", "14+", "18+", "Reserved"}
+	canadianFrenchRatingNames  = []string{"Exempt", "G", "8 ans +", "13 ans +", "16 ans +", "18 ans +", "Reserved", "Invalid"}
+)
+
+// Rating decodes the two content advisory characters.
+func (c XDSContentAdvisory) Rating() Rating {
+	a, b := c.Data[0], c.Data[1]
+	r := Rating{}
+	switch (a >> 3) & 0x03 { // a1 a0
+	case 0, 2:
+		r.System = RatingSystem_MPA
+		r.MPA = MPARating(a & 0x07)
+	case 1:
+		r.System = RatingSystem_USTV
+		r.TV = TVRating(b & 0x07)
+		// the flags only apply to some ratings, ignore the bits elsewhere
+		switch r.TV {
+		case TVRating_Y7:
+			r.FantasyViolence = b&0x20 == 0x20
+		case TVRating_PG, TVRating_14:
+			r.Dialogue = a&0x20 == 0x20
+			fallthrough
+		case TVRating_MA:
+			r.Language = b&0x08 == 0x08
+			r.Sex = b&0x10 == 0x10
+			r.Violence = b&0x20 == 0x20
+		}
+	case 3:
+		// a3 a2 select the system
+		switch (b>>2)&0x02 | (a>>5)&0x01 {
+		case 0:
+			r.System = RatingSystem_CanadianEnglish
+			r.CanadianEnglish = CanadianEnglishRating(b & 0x07)
+		case 1:
+			r.System = RatingSystem_CanadianFrench
+			r.CanadianFrench = CanadianFrenchRating(b & 0x07)
+		default:
+			r.System = RatingSystem_Reserved
+		}
+	}
+	return r
+}
+
+// String returns the rating as it is usually displayed, e.g. "TV-14-LV" or "PG-13".
+func (r Rating) String() string {
+	switch r.System {
+	case RatingSystem_MPA:
+		return mpaRatingNames[r.MPA&0x07]
+	case RatingSystem_USTV:
+		s := tvRatingNames[r.TV&0x07]
+		flags := ""
+		if r.Dialogue {
+			flags += "D"
+		}
+		if r.Language {
+			flags += "L"
+		}
+		if r.Sex {
+			flags += "S"
+		}
+		if r.Violence {
+			flags += "V"
+		}
+		if r.FantasyViolence {
+			flags += "FV"
+		}
+		if flags != "" {
+			s += "-" + flags
+		}
+		return s
+	case RatingSystem_CanadianEnglish:
+		return canadianEnglishRatingNames[r.CanadianEnglish&0x07]
+	case RatingSystem_CanadianFrench:
+		return canadianFrenchRatingNames[r.CanadianFrench&0x07]
+	case RatingSystem_Reserved:
+		return "Reserved"
+	}
+	return "None"
+}
+
+// Rating returns the content advisory of the current program, if one has been received.
+func (d *XDSDecoder) Rating() (Rating, bool) {
+	return d.rating, d.rating.System != RatingSystem_None
+}
+
+func (d *XDSDecoder) updateRating(pkt *XDSPacket) {
+	if pkt.Class != XDSClass_Current {
+		return
+	}
+	ca, ok := pkt.Content.(XDSContentAdvisory)
+	if !ok {
+		return
+	}
+	r := ca.Rating()
+	if r == d.rating {
+		return
+	}
+	change := RatingChange{Previous: d.rating, Current: r}
+	d.rating = r
+	if d.OnRatingChange != nil {
+		d.OnRatingChange(change)
+	}
+}
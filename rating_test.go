@@ -0,0 +1,93 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRating_ContentAdvisory(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		data     [2]byte
+		system   RatingSystem
+		expected string
+	}{
+		{[2]byte{0x43, 0x40}, RatingSystem_MPA, "PG-13"},
+		{[2]byte{0x47, 0x40}, RatingSystem_MPA, "Not Rated"},
+		{[2]byte{0x48, 0x6D}, RatingSystem_USTV, "TV-14-LV"},
+		{[2]byte{0x68, 0x44}, RatingSystem_USTV, "TV-PG-D"},
+		{[2]byte{0x48, 0x62}, RatingSystem_USTV, "TV-Y7-FV"},
+		{[2]byte{0x48, 0x7E}, RatingSystem_USTV, "TV-MA-LSV"},
+		{[2]byte{0x68, 0x7B}, RatingSystem_USTV, "TV-G"},
+		{[2]byte{0x68, 0x7E}, RatingSystem_USTV, "TV-MA-LSV"},
+		{[2]byte{0x48, 0x61}, RatingSystem_USTV, "TV-Y"},
+		{[2]byte{0x58, 0x42}, RatingSystem_CanadianEnglish, "C8+"},
+		{[2]byte{0x78, 0x43}, RatingSystem_CanadianFrench, "13 ans +"},
+		{[2]byte{0x78, 0x46}, RatingSystem_CanadianFrench, "Reserved"},
+		{[2]byte{0x78, 0x47}, RatingSystem_CanadianFrench, "Invalid"},
+		{[2]byte{0x58, 0x48}, RatingSystem_Reserved, "Reserved"},
+	}
+	for _, test := range tests {
+		r := XDSContentAdvisory{Data: test.data}.Rating()
+		assert.Equal(test.system, r.System)
+		assert.Equal(test.expected, r.String())
+	}
+
+	r := XDSContentAdvisory{Data: [2]byte{0x78, 0x47}}.Rating()
+	assert.Equal(CanadianFrenchRating_Invalid, r.CanadianFrench)
+}
+
+func TestRating_Changes(t *testing.T) {
+	assert := assert.New(t)
+
+	changes := []RatingChange{}
+	d := XDSDecoder{OnRatingChange: func(c RatingChange) { changes = append(changes, c) }}
+	_, ok := d.Rating()
+	assert.False(ok)
+
+	cc := xdsPacket(XDSClass_Current, xds_type_content_advisory, "\x48\x44")
+	cc = append(cc, xdsPacket(XDSClass_Current, xds_type_content_advisory, "\x48\x44")...)
+	// future programs do not change the current rating
+	cc = append(cc, xdsPacket(XDSClass_Future, xds_type_content_advisory, "\x43\x40")...)
+	cc = append(cc, xdsPacket(XDSClass_Current, xds_type_content_advisory, "\x48\x6D")...)
+	decodeXDS(t, &d, cc)
+
+	assert.Len(changes, 2)
+	assert.Equal(RatingSystem_None, changes[0].Previous.System)
+	assert.Equal("TV-PG", changes[0].Current.String())
+	assert.Equal("TV-PG", changes[1].Previous.String())
+	assert.Equal("TV-14-LV", changes[1].Current.String())
+
+	r, ok := d.Rating()
+	assert.True(ok)
+	assert.Equal(TVRating_14, r.TV)
+	assert.True(r.Language)
+	assert.True(r.Violence)
+}
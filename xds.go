@@ -0,0 +1,365 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+/*
+Decoder for EIA / CEA-608 Extended Data Services (XDS). XDS packets are carried in
+field 2, interleaved with CC3/CC4 and T3/T4 data.
+
+References: https://shop.cta.tech/products/line-21-data-services
+*/
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type XDSClass byte
+
+const (
+	XDSClass_Current       XDSClass = 0x01
+	XDSClass_Future        XDSClass = 0x03
+	XDSClass_Channel       XDSClass = 0x05
+	XDSClass_Misc          XDSClass = 0x07
+	XDSClass_PublicService XDSClass = 0x09
+	XDSClass_Reserved      XDSClass = 0x0B
+	XDSClass_Private       XDSClass = 0x0D
+)
+
+const (
+	// Current and Future class
+	xds_type_program_length     = 0x02
+	xds_type_program_name       = 0x03
+	xds_type_content_advisory   = 0x05
+	xds_type_caption_services   = 0x07
+	xds_type_caption_services_2 = 0x08
+
+	// Channel class
+	xds_type_network_name = 0x01
+	xds_type_call_letters = 0x02
+
+	// Misc class
+	xds_type_time_of_day = 0x01
+
+	xds_end = 0x0F
+
+	xds_max_data = 32
+)
+
+var (
+	ErrXDSChecksum = errors.New("xds checksum mismatch")
+	ErrXDSOverflow = errors.New("xds packet too long")
+)
+
+// XDSPacket is a complete, checksum verified XDS packet.
+type XDSPacket struct {
+	Class XDSClass
+	Type  byte
+	// Informational characters, without the trailing null padding
+	Data []byte
+	// Content holds the decoded packet for known types. One of XDSProgramName,
+	// XDSProgramLength, XDSContentAdvisory, XDSCaptionServices, XDSNetworkName,
+	// XDSCallLetters or XDSTimeOfDay. nil for other types.
+	Content interface{}
+}
+
+type XDSProgramName struct {
+	Name string
+}
+
+type XDSProgramLength struct {
+	Length time.Duration
+	// Time in show. Only set if HasElapsed is true
+	Elapsed    time.Duration
+	HasElapsed bool
+}
+
+// XDSContentAdvisory holds the two raw content advisory (V-chip) characters.
+type XDSContentAdvisory struct {
+	Data [2]byte
+}
+
+type XDSLanguage byte
+
+const (
+	XDSLanguage_Unknown XDSLanguage = iota
+	XDSLanguage_English
+	XDSLanguage_Spanish
+	XDSLanguage_French
+	XDSLanguage_German
+	XDSLanguage_Italian
+	XDSLanguage_Other
+	XDSLanguage_None
+)
+
+// XDSCaptionService describes one caption or text service available in the program.
+type XDSCaptionService struct {
+	Language XDSLanguage
+	Field    int
+	Channel  int // data channel 1 or 2
+	Text     bool
+}
+
+type XDSCaptionServices struct {
+	Services []XDSCaptionService
+}
+
+type XDSNetworkName struct {
+	Name string
+}
+
+type XDSCallLetters struct {
+	CallLetters string
+	// Native channel number, 0 if not sent
+	Channel int
+}
+
+type XDSTimeOfDay struct {
+	Time      time.Time // UTC
+	DayOfWeek time.Weekday
+	// Flags
+	DaylightSaving bool
+	LeapDay        bool
+	ZeroSeconds    bool
+	TapeDelayed    bool
+}
+
+type xdsBuffer struct {
+	started  bool
+	class    XDSClass
+	typ      byte
+	checksum int
+	data     []byte
+}
+
+// XDSDecoder reassembles XDS packets from field 2 byte pairs.
+type XDSDecoder struct {
+	// OnRatingChange, if set, is called whenever the content advisory of the
+	// current program changes, including the first one seen.
+	OnRatingChange func(RatingChange)
+
+	// in progress packet for each class, indexed by class>>1
+	packets [7]xdsBuffer
+	active  *xdsBuffer
+	rating  Rating
+}
+
+// IsXDS returns true if the pair starts, continues or ends an XDS packet.
+func IsXDS(ccData uint16) bool {
+	b := byte(ccData>>8) & 0x7F
+	return 0x01 <= b && b <= xds_end
+}
+
+// Decode a single, 2-byte pair from field 2. Returns a packet once it is complete.
+// Pairs that do not belong to XDS are ignored.
+func (d *XDSDecoder) Decode(ccData uint16) (*XDSPacket, error) {
+	// parity error, just skip it. The checksum will catch the damage
+	if parityWord(ccData) != ccData {
+		return nil, nil
+	}
+
+	ccData &= 0x7F7F // strip off parity bits
+	if ccData == 0 {
+		return nil, nil // padding
+	}
+
+	b1, b2 := byte(ccData>>8), byte(ccData)
+	switch {
+	case b1 == xds_end:
+		if d.active == nil {
+			return nil, nil
+		}
+		p := d.active
+		d.active = nil
+		pkt, err := p.finish(ccData)
+		if err != nil {
+			return nil, err
+		}
+		d.updateRating(pkt)
+		return pkt, nil
+
+	case b1 >= 0x01 && b1 < xds_end:
+		if b1&0x01 == 0x01 {
+			// start
+			p := &d.packets[b1>>1]
+			*p = xdsBuffer{started: true, class: XDSClass(b1), typ: b2, checksum: int(b1) + int(b2)}
+			d.active = p
+			return nil, nil
+		}
+		// continue. Not part of the checksum
+		d.active = nil
+		p := &d.packets[(b1-1)>>1]
+		if !p.started {
+			return nil, nil
+		}
+		if p.class != XDSClass(b1-1) || p.typ != b2 {
+			// the start of this packet was missed, and the one in
+			// progress can not be finished any more
+			*p = xdsBuffer{}
+			return nil, nil
+		}
+		d.active = p
+		return nil, nil
+
+	case b1 >= 0x10 && b1 < 0x20:
+		// caption control code, XDS is suspended until a continue
+		d.active = nil
+		return nil, nil
+	}
+
+	if d.active == nil {
+		return nil, nil // caption or text data
+	}
+	p := d.active
+	if len(p.data)+2 > xds_max_data {
+		*p = xdsBuffer{}
+		d.active = nil
+		return nil, ErrXDSOverflow
+	}
+	p.data = append(p.data, b1, b2)
+	p.checksum += int(b1) + int(b2)
+	return nil, nil
+}
+
+func (p *xdsBuffer) finish(ccData uint16) (*XDSPacket, error) {
+	sum := p.checksum + int(ccData>>8) + int(ccData&0x7F)
+	class, typ := p.class, p.typ
+	data := []byte(strings.TrimRight(string(p.data), "\x00"))
+	*p = xdsBuffer{}
+	if sum&0x7F != 0 {
+		return nil, ErrXDSChecksum
+	}
+
+	pkt := &XDSPacket{Class: class, Type: typ, Data: data}
+	pkt.Content = parseXDSContent(class, typ, data)
+	return pkt, nil
+}
+
+func parseXDSContent(class XDSClass, typ byte, data []byte) interface{} {
+	switch class {
+	case XDSClass_Current, XDSClass_Future:
+		switch typ {
+		case xds_type_program_length:
+			return parseXDSProgramLength(data)
+		case xds_type_program_name:
+			return XDSProgramName{Name: xdsString(data)}
+		case xds_type_content_advisory:
+			if len(data) < 2 {
+				return nil
+			}
+			return XDSContentAdvisory{Data: [2]byte{data[0], data[1]}}
+		case xds_type_caption_services, xds_type_caption_services_2:
+			return parseXDSCaptionServices(data)
+		}
+	case XDSClass_Channel:
+		switch typ {
+		case xds_type_network_name:
+			return XDSNetworkName{Name: xdsString(data)}
+		case xds_type_call_letters:
+			return parseXDSCallLetters(data)
+		}
+	case XDSClass_Misc:
+		switch typ {
+		case xds_type_time_of_day:
+			return parseXDSTimeOfDay(data)
+		}
+	}
+	return nil
+}
+
+func xdsString(data []byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		if b < 0x20 {
+			continue
+		}
+		sb.WriteRune(charMap[b-0x20])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func parseXDSProgramLength(data []byte) interface{} {
+	if len(data) < 2 {
+		return nil
+	}
+	l := XDSProgramLength{
+		Length: time.Duration(data[1]&0x1F)*time.Hour + time.Duration(data[0]&0x3F)*time.Minute,
+	}
+	if len(data) >= 4 {
+		l.HasElapsed = true
+		l.Elapsed = time.Duration(data[3]&0x1F)*time.Hour + time.Duration(data[2]&0x3F)*time.Minute
+		if len(data) >= 5 {
+			l.Elapsed += time.Duration(data[4]&0x3F) * time.Second
+		}
+	}
+	return l
+}
+
+func parseXDSCaptionServices(data []byte) interface{} {
+	s := XDSCaptionServices{}
+	for _, b := range data {
+		s.Services = append(s.Services, XDSCaptionService{
+			Language: XDSLanguage((b >> 3) & 0x07),
+			Field:    int((b>>2)&0x01) + 1,
+			Channel:  int((b>>1)&0x01) + 1,
+			Text:     b&0x01 == 0x01,
+		})
+	}
+	return s
+}
+
+func parseXDSCallLetters(data []byte) interface{} {
+	if len(data) < 4 {
+		return nil
+	}
+	c := XDSCallLetters{CallLetters: xdsString(data[:4])}
+	if len(data) >= 6 {
+		for _, b := range data[4:6] {
+			if b < '0' || b > '9' {
+				return c
+			}
+		}
+		c.Channel = int(data[4]-'0')*10 + int(data[5]-'0')
+	}
+	return c
+}
+
+func parseXDSTimeOfDay(data []byte) interface{} {
+	if len(data) < 6 {
+		return nil
+	}
+	t := XDSTimeOfDay{
+		DayOfWeek:      time.Weekday((data[4]&0x07 + 6) % 7), // 1 is Sunday
+		DaylightSaving: data[1]&0x20 == 0x20,
+		LeapDay:        data[2]&0x20 == 0x20,
+		ZeroSeconds:    data[3]&0x10 == 0x10,
+		TapeDelayed:    data[3]&0x20 == 0x20,
+	}
+	t.Time = time.Date(1990+int(data[5]&0x3F), time.Month(data[3]&0x0F), int(data[2]&0x1F),
+		int(data[1]&0x1F), int(data[0]&0x3F), 0, 0, time.UTC)
+	return t
+}
@@ -0,0 +1,140 @@
+package captions
+
+/**********************************************************************************************/
+/* The MIT License                                                                            */
+/*                                                                                            */
+/* Copyright 2016-2017 Twitch Interactive, Inc. or its affiliates. All Rights Reserved.       */
+/* golang Port Copyright (c) 2022 Mux (mux.com)                                                      */
+/*                                                                                            */
+/* Permission is hereby granted, free of charge, to any person obtaining a copy               */
+/* of this software and associated documentation files (the "Software"), to deal              */
+/* in the Software without restriction, including without limitation the rights               */
+/* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell                  */
+/* copies of the Software, and to permit persons to whom the Software is                      */
+/* furnished to do so, subject to the following conditions:                                   */
+/*                                                                                            */
+/* The above copyright notice and this permission notice shall be included in                 */
+/* all copies or substantial portions of the Software.                                        */
+/*                                                                                            */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR                 */
+/* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,                   */
+/* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE                */
+/* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER                     */
+/* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,              */
+/* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN                  */
+/* THE SOFTWARE.                                                                              */
+/**********************************************************************************************/
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// xdsPacket builds the pairs of a complete XDS packet, including the checksum
+func xdsPacket(class XDSClass, typ byte, data string) []uint16 {
+	if len(data)%2 == 1 {
+		data += "\x00"
+	}
+	sum := int(class) + int(typ) + xds_end
+	cc := []uint16{uint16(class)<<8 | uint16(typ)}
+	for i := 0; i < len(data); i += 2 {
+		cc = append(cc, uint16(data[i])<<8|uint16(data[i+1]))
+		sum += int(data[i]) + int(data[i+1])
+	}
+	return append(cc, xds_end<<8|uint16((128-sum%128)%128))
+}
+
+func decodeXDS(t *testing.T, d *XDSDecoder, cc []uint16) []*XDSPacket {
+	packets := []*XDSPacket{}
+	for _, c := range cc {
+		p, err := d.Decode(parityWord(c))
+		assert.Nil(t, err)
+		if p != nil {
+			packets = append(packets, p)
+		}
+	}
+	return packets
+}
+
+func TestXDS_Packets(t *testing.T) {
+	assert := assert.New(t)
+
+	d := XDSDecoder{}
+	cc := xdsPacket(XDSClass_Current, xds_type_program_name, "NEWS AT 9")
+	cc = append(cc, xdsPacket(XDSClass_Current, xds_type_program_length, "\x5E\x41\x4F\x40\x5E")...)
+	cc = append(cc, xdsPacket(XDSClass_Channel, xds_type_call_letters, "WXYZ12")...)
+	cc = append(cc, xdsPacket(XDSClass_Misc, xds_type_time_of_day, "\x5E\x72\x4F\x43\x47\x60")...)
+	cc = append(cc, xdsPacket(XDSClass_Current, xds_type_caption_services_2, "\x48\x51")...)
+	packets := decodeXDS(t, &d, cc)
+	assert.Len(packets, 5)
+
+	assert.Equal(XDSProgramName{Name: "NEWS AT 9"}, packets[0].Content)
+	assert.Equal(XDSProgramLength{
+		Length:     time.Hour + 30*time.Minute,
+		Elapsed:    15*time.Minute + 30*time.Second,
+		HasElapsed: true,
+	}, packets[1].Content)
+	assert.Equal(XDSCallLetters{CallLetters: "WXYZ", Channel: 12}, packets[2].Content)
+	tod := packets[3].Content.(XDSTimeOfDay)
+	assert.Equal(time.Date(2022, time.March, 15, 18, 30, 0, 0, time.UTC), tod.Time)
+	assert.Equal(time.Saturday, tod.DayOfWeek)
+	assert.True(tod.DaylightSaving)
+	assert.Equal(XDSCaptionServices{Services: []XDSCaptionService{
+		{Language: XDSLanguage_English, Field: 1, Channel: 1},
+		{Language: XDSLanguage_Spanish, Field: 1, Channel: 1, Text: true},
+	}}, packets[4].Content)
+}
+
+func TestXDS_Interleaved(t *testing.T) {
+	assert := assert.New(t)
+
+	name := xdsPacket(XDSClass_Current, xds_type_program_name, "ABCD")
+	network := xdsPacket(XDSClass_Channel, xds_type_network_name, "PBS")
+
+	// the program name is interrupted by a network name and by CC3 captions
+	cc := []uint16{name[0], name[1]}
+	cc = append(cc, network...)
+	cc = append(cc, 0x1425, 0x1425, 0x4849) // RU2, "HI"
+	cc = append(cc, uint16(XDSClass_Current+1)<<8|xds_type_program_name)
+	cc = append(cc, name[2:]...)
+
+	d := XDSDecoder{}
+	packets := decodeXDS(t, &d, cc)
+	assert.Len(packets, 2)
+	assert.Equal(XDSNetworkName{Name: "PBS"}, packets[0].Content)
+	assert.Equal(XDSProgramName{Name: "ABCD"}, packets[1].Content)
+
+	// captions on the same field are not confused with XDS data
+	eia608 := EIA608Frame{}
+	for _, c := range cc {
+		_, err := eia608.DecodeField(2, parityWord(c))
+		assert.Nil(err)
+	}
+	assert.Equal("HI", eia608.ChannelString(Channel608_CC3))
+
+	// a continue for another type of the same class does not join the
+	// program name, which is dropped
+	length := xdsPacket(XDSClass_Current, xds_type_program_length, "\x5E\x41")
+	cc = []uint16{name[0], name[1]}
+	cc = append(cc, uint16(XDSClass_Current+1)<<8|xds_type_program_length)
+	cc = append(cc, length[1:]...)
+	cc = append(cc, uint16(XDSClass_Current+1)<<8|xds_type_program_name)
+	cc = append(cc, name[2:]...)
+	assert.Empty(decodeXDS(t, &d, cc))
+}
+
+func TestXDS_Checksum(t *testing.T) {
+	assert := assert.New(t)
+
+	cc := xdsPacket(XDSClass_Current, xds_type_program_name, "ABCD")
+	cc[len(cc)-1]++
+
+	d := XDSDecoder{}
+	var err error
+	for _, c := range cc {
+		_, err = d.Decode(parityWord(c))
+	}
+	assert.Equal(ErrXDSChecksum, err)
+}